@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/google/pprof v0.0.0-20260202012954-cb029daf43ef
 	github.com/spf13/cobra v1.10.2
+	golang.org/x/exp v0.0.0-20260209203927-2842357ff358
 )
 
 require (
@@ -14,6 +14,7 @@ var (
 	diffTopN      int
 	diffBaseType  string
 	diffNewType   string
+	diffFormat    string
 )
 
 var diffCmd = &cobra.Command{
@@ -26,7 +27,8 @@ The profile types are auto-detected, but must match between files.
 
 Example:
   go-pprof-md diff base.prof new.prof
-  go-pprof-md diff -o diff.md before.prof after.prof`,
+  go-pprof-md diff -o diff.md before.prof after.prof
+  go-pprof-md diff -f svg -o diff.svg before.prof after.prof`,
 	Args: cobra.ExactArgs(2),
 	RunE: runDiff,
 }
@@ -38,12 +40,17 @@ func init() {
 	diffCmd.Flags().IntVarP(&diffTopN, "top", "n", 20, "Number of top changed functions to display")
 	diffCmd.Flags().StringVarP(&diffBaseType, "base-type", "b", "", "Base profile type (auto-detected if not specified)")
 	diffCmd.Flags().StringVarP(&diffNewType, "new-type", "t", "", "New profile type (auto-detected if not specified)")
+	diffCmd.Flags().StringVarP(&diffFormat, "format", "f", formatMarkdown, "Output format: markdown, dot or svg (svg requires Graphviz)")
 }
 
 func runDiff(cmd *cobra.Command, args []string) error {
 	baseFile := args[0]
 	newFile := args[1]
 
+	if err := checkFormat(diffFormat, formatMarkdown, formatDOT, formatSVG); err != nil {
+		return err
+	}
+
 	// Check if files exist
 	for _, f := range []string{baseFile, newFile} {
 		if _, err := os.Stat(f); os.IsNotExist(err) {
@@ -92,6 +99,14 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		generator.WithDiffTopN(diffTopN),
 	)
 
+	if diffFormat != formatMarkdown {
+		graph, err := renderGraph(gen, diffFormat)
+		if err != nil {
+			return err
+		}
+		return writeOutput(diffOutput, graph, "Diff call graph")
+	}
+
 	markdown, err := gen.Generate()
 	if err != nil {
 		return fmt.Errorf("failed to generate diff: %w", err)
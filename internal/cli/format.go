@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Output formats accepted by --format
+const (
+	formatMarkdown   = "markdown"
+	formatDOT        = "dot"
+	formatSVG        = "svg"
+	formatFolded     = "folded"
+	formatHTML       = "html"
+	formatSpeedscope = "speedscope"
+	formatJSON       = "json"
+)
+
+// graphRenderer is implemented by generators that can draw a call graph
+type graphRenderer interface {
+	GenerateDOT() (string, error)
+}
+
+// renderGraph renders a call graph as DOT, or as SVG through Graphviz
+func renderGraph(gen graphRenderer, format string) ([]byte, error) {
+	dot, err := gen.GenerateDOT()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate call graph: %w", err)
+	}
+	if format == formatDOT {
+		return []byte(dot), nil
+	}
+
+	path, err := exec.LookPath("dot")
+	if err != nil {
+		return nil, fmt.Errorf("svg output requires Graphviz (dot) in PATH; use --format dot instead")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "-Tsvg")
+	cmd.Stdin = strings.NewReader(dot)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run dot: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// checkFormat validates a --format value against the formats a command
+// supports
+func checkFormat(format string, valid ...string) error {
+	for _, v := range valid {
+		if format == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format: %s (valid: %s)", format, strings.Join(valid, ", "))
+}
+
+// writeOutput writes a report to the output file, or stdout if none is set
+func writeOutput(output string, contents []byte, kind string) error {
+	if output == "" {
+		_, err := os.Stdout.Write(contents)
+		return err
+	}
+	if err := os.WriteFile(output, contents, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s written to: %s\n", kind, output)
+	return nil
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"runtime/debug"
+
+	"github.com/alingse/go-pprof-md/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+var serveMCPCmd = &cobra.Command{
+	Use:   "serve-mcp",
+	Short: "Serve profile analysis tools over the Model Context Protocol",
+	Long: `Serve go-pprof-md as a Model Context Protocol (MCP) server on stdin and
+stdout, so AI assistants can query profiles interactively instead of
+reading a whole report.
+
+Tools:
+  show_profile    Full report of a profile (markdown or JSON)
+  diff_profiles   Functions that changed most between two profiles
+  list_top        Table of the top functions
+  get_call_paths  Call paths, callers and callees of a function
+  get_source      Source code around a function's hot line
+
+Example client configuration:
+  {"mcpServers": {"pprof": {"command": "go-pprof-md", "args": ["serve-mcp"]}}}`,
+	Args: cobra.NoArgs,
+	RunE: runServeMCP,
+}
+
+func init() {
+	rootCmd.AddCommand(serveMCPCmd)
+}
+
+func runServeMCP(cmd *cobra.Command, args []string) error {
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	// Clients shut the server down by closing stdin; Ctrl-C exits as usual
+	return mcp.NewServer(version).Serve(context.Background(), os.Stdin, os.Stdout)
+}
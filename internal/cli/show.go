@@ -3,6 +3,8 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 
 	"github.com/alingse/go-pprof-md/internal/generator"
 	"github.com/alingse/go-pprof-md/internal/parser"
@@ -14,6 +16,24 @@ var (
 	topN        int
 	noAIPrompt  bool
 	profileType string
+	maxTokens   int
+	split       bool
+	partSize    int
+	sourceDir   string
+	maxPaths    int
+	pathThresh  float64
+	compact     []string
+	callTree    bool
+	nodeFrac    float64
+	peek        bool
+	mermaid     bool
+	edgeFrac    float64
+	showFormat  string
+	sampleIndex string
+	flame       bool
+	explainDet  bool
+	focus       string
+	ignore      string
 )
 
 var showCmd = &cobra.Command{
@@ -22,7 +42,16 @@ var showCmd = &cobra.Command{
 	Long: `Show a pprof file (CPU, heap, goroutine, or mutex) as
 a markdown report optimized for AI analysis.
 
-The profile type is auto-detected from the file content.`,
+The profile type is auto-detected from the file content. Profiles of
+other types, such as custom pprof.NewProfile profiles, are reported
+generically by their default sample type. Goroutine
+text dumps (debug=2), folded stacks and Linux perf script output
+(read as CPU profiles) are also accepted.
+
+Execution traces (runtime/trace, /debug/pprof/trace) are read as a
+scheduler latency profile with a trace summary of GC pauses and proc
+utilization. Use -t syscall, net or sync for syscall, network blocking
+or synchronization blocking profiles, like go tool trace -pprof.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runShow,
 }
@@ -33,17 +62,54 @@ func init() {
 	showCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
 	showCmd.Flags().IntVarP(&topN, "top", "n", 20, "Number of top functions to display")
 	showCmd.Flags().BoolVar(&noAIPrompt, "no-ai-prompt", false, "Disable AI analysis prompt")
-	showCmd.Flags().StringVarP(&profileType, "type", "t", "", "Profile type (cpu, heap, goroutine, mutex). Auto-detected if not specified")
+	showCmd.Flags().StringVarP(&profileType, "type", "t", "", "Profile type (cpu, heap, goroutine, mutex, generic; sched, syscall, net, sync for execution traces). Auto-detected if not specified")
+	showCmd.Flags().BoolVar(&explainDet, "explain-detect", false, "Print how the profile type was detected, with the evidence for each candidate type, instead of the report")
+	showCmd.Flags().StringVarP(&showFormat, "format", "f", formatMarkdown, "Output format: markdown, json, html, dot, svg (requires Graphviz), folded or speedscope")
+	showCmd.Flags().StringVar(&sampleIndex, "sample-index", "", "Sample type to report, e.g. inuse_space or alloc_objects (default: the profile type's usual metric)")
+	showCmd.Flags().StringVar(&focus, "focus", "", "Only report samples with a function matching this regexp")
+	showCmd.Flags().StringVar(&ignore, "ignore", "", "Drop samples with a function matching this regexp")
+	showCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Trim the report to about this many LLM tokens (0 = no limit)")
+	showCmd.Flags().IntVar(&maxPaths, "max-paths", 0, "Maximum call paths per function (0 = all)")
+	showCmd.Flags().Float64Var(&pathThresh, "path-threshold", 0, "Hide call paths below this percentage of the function's flat value")
+	showCmd.Flags().StringSliceVar(&compact, "compact", nil, "Compact call path stacks: runtime, recursion, root, paths or all (bare --compact means all)")
+	showCmd.Flags().Lookup("compact").NoOptDefVal = "all"
+	showCmd.Flags().BoolVar(&flame, "flame", false, "Add a text flame graph of the sampled stacks")
+	showCmd.Flags().BoolVar(&mermaid, "mermaid", false, "Add a Mermaid flowchart of the hot call graph")
+	showCmd.Flags().Float64Var(&edgeFrac, "edge-fraction", generator.DefaultEdgeFraction, "Hide call graph edges below this fraction of the total")
+	showCmd.Flags().BoolVar(&peek, "peek", false, "Add tables of the immediate callers and callees of each top function")
+	showCmd.Flags().BoolVar(&callTree, "tree", false, "Add a top-down call tree and a caller tree for each top function")
+	showCmd.Flags().Float64Var(&nodeFrac, "node-fraction", generator.DefaultNodeFraction, "Hide call tree and call graph nodes below this fraction of the total")
+	showCmd.Flags().BoolVar(&split, "split", false, "Write an index plus linked part files into the --output directory")
+	showCmd.Flags().IntVar(&partSize, "part-size", generator.DefaultPartSize, "Maximum size of each part in bytes with --split")
+	showCmd.Flags().StringVar(&sourceDir, "source-dir", "", "Directory to find source files in for the --split source listings when the profile's paths are from another machine")
 }
 
 func runShow(cmd *cobra.Command, args []string) error {
 	filename := args[0]
 
+	if err := checkFormat(showFormat, formatMarkdown, formatJSON, formatHTML, formatDOT, formatSVG, formatFolded, formatSpeedscope); err != nil {
+		return err
+	}
+	if split && showFormat != formatMarkdown {
+		return fmt.Errorf("--split only supports markdown output")
+	}
+	if split && maxTokens > 0 {
+		return fmt.Errorf("--max-tokens cannot be combined with --split; use --part-size to bound each part")
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return fmt.Errorf("file not found: %s", filename)
 	}
 
+	if explainDet {
+		detection, err := parser.DetectProfile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to detect profile type: %w", err)
+		}
+		return writeOutput(outputFile, []byte(detection.Explain()), "Detection")
+	}
+
 	// Parse profile
 	var profile *parser.Profile
 	var err error
@@ -62,13 +128,71 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse profile: %w", err)
 	}
 
+	profile, err = filterProfile(profile, focus, ignore)
+	if err != nil {
+		return err
+	}
+
+	compaction, err := generator.ParseStackCompaction(compact)
+	if err != nil {
+		return err
+	}
+
 	// Generate markdown
 	gen := generator.NewGenerator(
 		profile,
 		generator.WithTopN(topN),
 		generator.WithAIPrompt(!noAIPrompt),
+		generator.WithMaxTokens(maxTokens),
+		generator.WithMaxPaths(maxPaths),
+		generator.WithPathThreshold(pathThresh),
+		generator.WithStackCompaction(compaction),
+		generator.WithSampleIndex(sampleIndex),
+		generator.WithFlameGraph(flame),
+		generator.WithMermaid(mermaid),
+		generator.WithEdgeFraction(edgeFrac),
+		generator.WithPeek(peek),
+		generator.WithCallTree(callTree),
+		generator.WithNodeFraction(nodeFrac),
+		generator.WithSourceDir(sourceDir),
 	)
 
+	if split {
+		return writeSplitReport(gen)
+	}
+	switch showFormat {
+	case formatDOT, formatSVG:
+		graph, err := renderGraph(gen, showFormat)
+		if err != nil {
+			return err
+		}
+		return writeOutput(outputFile, graph, "Call graph")
+	case formatJSON:
+		report, err := gen.GenerateJSON()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %w", err)
+		}
+		return writeOutput(outputFile, []byte(report), "JSON report")
+	case formatHTML:
+		html, err := gen.GenerateHTML()
+		if err != nil {
+			return fmt.Errorf("failed to generate HTML: %w", err)
+		}
+		return writeOutput(outputFile, []byte(html), "HTML report")
+	case formatFolded:
+		folded, err := gen.GenerateFolded()
+		if err != nil {
+			return fmt.Errorf("failed to generate folded stacks: %w", err)
+		}
+		return writeOutput(outputFile, []byte(folded), "Folded stacks")
+	case formatSpeedscope:
+		speedscope, err := gen.GenerateSpeedscope()
+		if err != nil {
+			return fmt.Errorf("failed to generate speedscope profile: %w", err)
+		}
+		return writeOutput(outputFile, []byte(speedscope), "Speedscope profile")
+	}
+
 	markdown, err := gen.Generate()
 	if err != nil {
 		return fmt.Errorf("failed to generate markdown: %w", err)
@@ -86,3 +210,49 @@ func runShow(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// filterProfile applies the --focus and --ignore regexps to a profile
+func filterProfile(profile *parser.Profile, focus, ignore string) (*parser.Profile, error) {
+	var focusRE, ignoreRE *regexp.Regexp
+	var err error
+	if focus != "" {
+		if focusRE, err = regexp.Compile(focus); err != nil {
+			return nil, fmt.Errorf("invalid --focus: %w", err)
+		}
+	}
+	if ignore != "" {
+		if ignoreRE, err = regexp.Compile(ignore); err != nil {
+			return nil, fmt.Errorf("invalid --ignore: %w", err)
+		}
+	}
+
+	profile, err = profile.Filter(focusRE, ignoreRE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter profile: %w", err)
+	}
+	return profile, nil
+}
+
+// writeSplitReport writes a split report into the output directory
+func writeSplitReport(gen *generator.Generator) error {
+	if outputFile == "" {
+		return fmt.Errorf("--split requires --output to name a directory")
+	}
+
+	parts, err := gen.GenerateSplit(partSize)
+	if err != nil {
+		return fmt.Errorf("failed to generate markdown: %w", err)
+	}
+
+	if err := os.MkdirAll(outputFile, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for _, part := range parts {
+		if err := os.WriteFile(filepath.Join(outputFile, part.Name), []byte(part.Body), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Split report written to: %s (%d files, start with %s)\n", outputFile, len(parts), parts[0].Name)
+
+	return nil
+}
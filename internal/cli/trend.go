@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alingse/go-pprof-md/internal/generator"
+	"github.com/alingse/go-pprof-md/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trendOutput string
+	trendTopN   int
+	trendType   string
+)
+
+var trendCmd = &cobra.Command{
+	Use:   "trend <profile> <profile>...",
+	Short: "Show how a series of pprof files evolves over time",
+	Long: `Show how totals and top functions evolve across a series of pprof files.
+This is useful for investigating slow growth, such as heap usage that
+creeps up over hours.
+
+Profiles are ordered by their collection timestamp. If any profile has no
+timestamp, they are ordered by file name instead. All profiles must be the
+same type.
+
+Example:
+  go-pprof-md trend heap-1.prof heap-2.prof heap-3.prof
+  go-pprof-md trend -o trend.md snapshots/*.prof`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runTrend,
+}
+
+func init() {
+	rootCmd.AddCommand(trendCmd)
+
+	trendCmd.Flags().StringVarP(&trendOutput, "output", "o", "", "Output file (default: stdout)")
+	trendCmd.Flags().IntVarP(&trendTopN, "top", "n", 20, "Number of top functions to display")
+	trendCmd.Flags().StringVarP(&trendType, "type", "t", "", "Profile type (auto-detected if not specified)")
+}
+
+func runTrend(cmd *cobra.Command, args []string) error {
+	points, err := parseTrendPoints(args, trendType)
+	if err != nil {
+		return err
+	}
+
+	// Generate trend markdown
+	gen := generator.NewTrendGenerator(points,
+		generator.WithTrendTopN(trendTopN),
+	)
+
+	markdown, err := gen.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate trend: %w", err)
+	}
+
+	// Write output
+	if trendOutput != "" {
+		if err := os.WriteFile(trendOutput, []byte(markdown), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Trend report written to: %s\n", trendOutput)
+	} else {
+		fmt.Print(markdown)
+	}
+
+	return nil
+}
+
+// parseTrendPoints parses every file into a trend point and checks that
+// all profiles share the same type
+func parseTrendPoints(files []string, typ string) ([]generator.TrendPoint, error) {
+	points := make([]generator.TrendPoint, 0, len(files))
+	for _, f := range files {
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", f)
+		}
+
+		var profile *parser.Profile
+		var err error
+		if typ != "" {
+			p := parser.NewParser(parser.ProfileType(typ))
+			if p == nil {
+				return nil, fmt.Errorf("invalid profile type: %s", typ)
+			}
+			profile, err = p.Parse(f)
+		} else {
+			profile, err = parser.Parse(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse profile %s: %w", f, err)
+		}
+
+		if len(points) > 0 && points[0].Profile.Type != profile.Type {
+			return nil, fmt.Errorf("profile types do not match: %s is %s, %s is %s",
+				points[0].Label, points[0].Profile.Type, f, profile.Type)
+		}
+
+		points = append(points, generator.TrendPoint{
+			Label:   f,
+			Profile: profile,
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,310 @@
+package generator
+
+import (
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// charsPerToken is the average number of characters per LLM token used to
+// estimate report size. Markdown tables and Go identifiers tokenize at
+// roughly this rate for common tokenizers.
+const charsPerToken = 4
+
+// minBudgetFrames is the number of leaf-side frames kept per call path
+// before the budget starts dropping whole rows
+const minBudgetFrames = 4
+
+// EstimateTokens estimates the number of LLM tokens in s
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken
+}
+
+// reportLayout controls how much detail the report includes
+type reportLayout struct {
+	topN      int  // Number of functions in the report
+	maxPaths  int  // Call paths per function (-1 for all)
+	maxFrames int  // Leaf-side frames per call path (-1 for all)
+	peek      bool // Callers and callees tables
+	tree      bool // Call tree and caller trees
+	mermaid   bool // Mermaid call graph
+	flame     bool // Text flame graph
+}
+
+// defaultLayout returns the layout requested by the generator options
+func (g *Generator) defaultLayout() reportLayout {
+	return reportLayout{
+		topN:      g.topN,
+		maxPaths:  -1,
+		maxFrames: -1,
+		peek:      g.includePeek,
+		tree:      g.includeCallTree,
+		mermaid:   g.includeMermaid,
+		flame:     g.includeFlame,
+	}
+}
+
+// reportSections holds the optional sections of a report. They are built
+// once per report so that fitting a token budget re-renders them without
+// rebuilding the call trees.
+type reportSections struct {
+	callTree    []TreeRow
+	callerTrees map[string][]TreeRow // By function name
+	callGraph   CallGraph
+	flame       []string
+	flameNotes  []string
+}
+
+// buildSections builds the optional sections enabled in layout
+func (g *Generator) buildSections(layout reportLayout) *reportSections {
+	sections := &reportSections{}
+	if layout.tree {
+		sections.callTree = g.callTree()
+		sections.callerTrees = make(map[string][]TreeRow)
+		for i, fn := range g.profile.Functions {
+			if i >= layout.topN {
+				break
+			}
+			sections.callerTrees[fn.Name] = g.callerTree(fn)
+		}
+	}
+	if layout.mermaid {
+		sections.callGraph = g.buildCallGraph()
+	}
+	if layout.flame {
+		sections.flame, sections.flameNotes = g.textFlameGraph()
+	}
+	return sections
+}
+
+// Omissions counts what a layout left out of the report
+type Omissions struct {
+	Functions int
+	CallPaths int
+	Frames    int
+	Sections  []string // Optional sections left out, e.g. "the flame graph"
+}
+
+// Any reports whether anything was omitted
+func (o Omissions) Any() bool {
+	return o.Functions > 0 || o.CallPaths > 0 || o.Frames > 0 || len(o.Sections) > 0
+}
+
+// String lists the omitted items, e.g. "3 lower-ranked functions and 12 call paths"
+func (o Omissions) String() string {
+	var parts []string
+	if o.Functions > 0 {
+		parts = append(parts, pluralize(o.Functions, "lower-ranked function"))
+	}
+	if o.CallPaths > 0 {
+		parts = append(parts, pluralize(o.CallPaths, "call path"))
+	}
+	if o.Frames > 0 {
+		parts = append(parts, pluralize(o.Frames, "outer stack frame"))
+	}
+	parts = append(parts, o.Sections...)
+	switch len(parts) {
+	case 0:
+		return "nothing"
+	case 1:
+		return parts[0]
+	default:
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+}
+
+// FunctionView is a Function prepared for rendering
+type FunctionView struct {
+	parser.Function
+	Rank          int // 1-based position in the report
+	CallPaths     []PathView
+	OmittedPaths  int       // Call paths left out of CallPaths
+	OmittedWeight int64     // Combined weight of the omitted call paths
+	OmittedPct    float64   // OmittedWeight as a percentage of the profile total
+	CommonRoot    []string  // Root frames trimmed from every call path
+	CallerTree    []TreeRow // Inverted caller tree, when call trees are enabled
+}
+
+// PathView is a CallPath prepared for rendering
+type PathView struct {
+	parser.CallPath
+	Pct           float64 // Weight as a percentage of the profile total
+	OmittedFrames int     // Root-side frames left out of Stack
+	ElidedFrames  int     // Runtime and standard library frames left out of Stack
+}
+
+// applyLayout selects the functions, call paths and frames to render
+func (g *Generator) applyLayout(layout reportLayout) ([]FunctionView, Omissions) {
+	var omitted Omissions
+
+	functions := g.profile.Functions
+	if len(functions) > layout.topN {
+		functions = functions[:layout.topN]
+	}
+	if requested := min(len(g.profile.Functions), g.topN); requested > len(functions) {
+		omitted.Functions = requested - len(functions)
+	}
+	for _, section := range []struct {
+		requested, included bool
+		name                string
+	}{
+		{g.includeFlame, layout.flame, "the flame graph"},
+		{g.includeMermaid, layout.mermaid, "the call graph"},
+		{g.includeCallTree, layout.tree, "the call trees"},
+		{g.includePeek, layout.peek, "the callers and callees tables"},
+	} {
+		if section.requested && !section.included {
+			omitted.Sections = append(omitted.Sections, section.name)
+		}
+	}
+
+	total := g.profile.TotalSamples
+	pct := func(v int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(v) / float64(total) * 100
+	}
+
+	views := make([]FunctionView, 0, len(functions))
+	for i, fn := range functions {
+		view := FunctionView{Function: fn, Rank: i + 1}
+
+		// Paths the user asked to see, then whatever fits the token budget
+		limit := g.selectPaths(fn)
+		if layout.maxPaths >= 0 && limit > layout.maxPaths {
+			omitted.CallPaths += limit - layout.maxPaths
+			limit = layout.maxPaths
+		}
+
+		for _, path := range fn.CallPaths[:limit] {
+			view.CallPaths = append(view.CallPaths, PathView{CallPath: path, Pct: pct(path.Weight)})
+		}
+		view.CommonRoot = g.compaction.compactPaths(view.CallPaths)
+		for j := range view.CallPaths {
+			pv := &view.CallPaths[j]
+			if layout.maxFrames >= 0 && len(pv.Stack) > layout.maxFrames {
+				pv.OmittedFrames = len(pv.Stack) - layout.maxFrames
+				pv.Stack = pv.Stack[pv.OmittedFrames:]
+				omitted.Frames += pv.OmittedFrames
+			}
+		}
+		for _, path := range fn.CallPaths[limit:] {
+			view.OmittedPaths++
+			view.OmittedWeight += path.Weight
+		}
+		view.OmittedPct = pct(view.OmittedWeight)
+
+		views = append(views, view)
+	}
+
+	return views, omitted
+}
+
+// selectPaths returns how many of the function's call paths (heaviest first)
+// pass the path threshold and the per-function path limit
+func (g *Generator) selectPaths(fn parser.Function) int {
+	n := len(fn.CallPaths)
+	if g.pathThreshold > 0 && fn.Flat > 0 {
+		for i, path := range fn.CallPaths {
+			if float64(path.Weight)/float64(fn.Flat)*100 < g.pathThreshold {
+				n = i
+				break
+			}
+		}
+	}
+	if g.maxPaths > 0 && n > g.maxPaths {
+		n = g.maxPaths
+	}
+	return n
+}
+
+// fitTokenBudget renders the report with progressively less detail until it
+// fits within maxTokens. If even the smallest layout is too large, the
+// smallest layout is returned.
+func (g *Generator) fitTokenBudget(tmpl *template.Template) (string, error) {
+	layout := g.defaultLayout()
+	sections := g.buildSections(layout)
+	for {
+		out, err := g.render(tmpl, layout, sections)
+		if err != nil {
+			return "", err
+		}
+		if EstimateTokens(out) <= g.maxTokens {
+			return out, nil
+		}
+
+		next, ok := g.shrinkLayout(layout)
+		if !ok {
+			return out, nil
+		}
+		layout = next
+	}
+}
+
+// shrinkLayout returns the next, smaller layout. Detail that helps least is
+// dropped first: extra call paths, then outer frames, then the optional
+// sections one by one, then low-weight rows, and finally call paths
+// altogether.
+func (g *Generator) shrinkLayout(layout reportLayout) (reportLayout, bool) {
+	// Resolve "all" to concrete sizes so they can be reduced
+	if layout.maxPaths < 0 {
+		layout.maxPaths = g.maxCallPaths(layout.topN)
+	}
+	if layout.maxFrames < 0 {
+		layout.maxFrames = g.maxStackDepth(layout.topN)
+	}
+
+	switch {
+	case layout.maxPaths > 1:
+		layout.maxPaths /= 2
+	case layout.maxFrames > minBudgetFrames:
+		layout.maxFrames = max(layout.maxFrames/2, minBudgetFrames)
+	case layout.flame:
+		layout.flame = false
+	case layout.mermaid:
+		layout.mermaid = false
+	case layout.tree:
+		layout.tree = false
+	case layout.peek:
+		layout.peek = false
+	case layout.topN > 5:
+		layout.topN = layout.topN * 3 / 4
+	case layout.maxPaths > 0:
+		layout.maxPaths = 0
+	case layout.topN > 1:
+		layout.topN--
+	default:
+		return layout, false
+	}
+	return layout, true
+}
+
+// maxCallPaths returns the largest number of call paths shown for any of
+// the top n functions
+func (g *Generator) maxCallPaths(n int) int {
+	result := 0
+	for i, fn := range g.profile.Functions {
+		if i >= n {
+			break
+		}
+		result = max(result, g.selectPaths(fn))
+	}
+	return result
+}
+
+// maxStackDepth returns the deepest call path of the top n functions
+func (g *Generator) maxStackDepth(n int) int {
+	result := 0
+	for i, fn := range g.profile.Functions {
+		if i >= n {
+			break
+		}
+		for _, path := range fn.CallPaths {
+			result = max(result, len(path.Stack))
+		}
+	}
+	return result
+}
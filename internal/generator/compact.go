@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StackCompaction selects how call path stacks are compacted for rendering
+type StackCompaction struct {
+	ElideRuntime      bool // Drop runtime and standard library frames at the root
+	CollapseRecursion bool // Render repeated frames as "f ×3"
+	TrimCommonRoot    bool // Drop root frames shared by all paths of a function
+	ShortenPaths      bool // Render "github.com/a/b/pkg.F" as "pkg.F"
+}
+
+// CompactionModes lists the names accepted by ParseStackCompaction
+var CompactionModes = []string{"runtime", "recursion", "root", "paths", "all"}
+
+// ParseStackCompaction builds a StackCompaction from mode names such as
+// "runtime" or "all"
+func ParseStackCompaction(modes []string) (StackCompaction, error) {
+	var c StackCompaction
+	for _, mode := range modes {
+		switch strings.TrimSpace(mode) {
+		case "runtime":
+			c.ElideRuntime = true
+		case "recursion":
+			c.CollapseRecursion = true
+		case "root":
+			c.TrimCommonRoot = true
+		case "paths":
+			c.ShortenPaths = true
+		case "all":
+			c = StackCompaction{ElideRuntime: true, CollapseRecursion: true, TrimCommonRoot: true, ShortenPaths: true}
+		case "", "none":
+		default:
+			return c, fmt.Errorf("invalid compaction mode: %s (valid: %s)", mode, strings.Join(CompactionModes, ", "))
+		}
+	}
+	return c, nil
+}
+
+// compactPaths compacts the stacks of one function's call paths in place and
+// returns the root frames trimmed from all of them
+func (c StackCompaction) compactPaths(paths []PathView) []string {
+	for i := range paths {
+		stack := paths[i].Stack
+		if c.ElideRuntime {
+			n := runtimePrefix(stack)
+			paths[i].ElidedFrames = n
+			stack = stack[n:]
+		}
+		if c.CollapseRecursion {
+			stack = collapseRecursion(stack)
+		}
+		paths[i].Stack = stack
+	}
+
+	var root []string
+	if c.TrimCommonRoot && len(paths) > 1 {
+		n := commonRoot(paths)
+		root = paths[0].Stack[:n]
+		for i := range paths {
+			paths[i].Stack = paths[i].Stack[n:]
+		}
+	}
+
+	if c.ShortenPaths {
+		for i := range paths {
+			paths[i].Stack = shortenFrames(paths[i].Stack)
+		}
+		root = shortenFrames(root)
+	}
+	return root
+}
+
+// runtimePrefix returns the number of runtime and standard library frames at
+// the root of stack. The leaf is always kept.
+func runtimePrefix(stack []string) int {
+	n := 0
+	for n < len(stack)-1 && isStdlibFrame(stack[n]) {
+		n++
+	}
+	return n
+}
+
+//go:generate go run gen_stdlib.go
+
+// isStdlibFrame reports whether a frame belongs to the Go runtime or
+// standard library. Module paths without a dot, such as "myapp/store", are
+// not standard library, so the package is looked up in stdlibPackages.
+func isStdlibFrame(frame string) bool {
+	// Type arguments may contain slashes of their own
+	name, _, _ := strings.Cut(frame, "[")
+	return stdlibPackages[packageName(name)]
+}
+
+// collapseRecursion replaces runs of the same frame with a single "f ×N"
+func collapseRecursion(stack []string) []string {
+	result := make([]string, 0, len(stack))
+	for i := 0; i < len(stack); {
+		j := i + 1
+		for j < len(stack) && stack[j] == stack[i] {
+			j++
+		}
+		if j-i > 1 {
+			result = append(result, fmt.Sprintf("%s ×%d", stack[i], j-i))
+		} else {
+			result = append(result, stack[i])
+		}
+		i = j
+	}
+	return result
+}
+
+// commonRoot returns the number of root frames shared by all paths, leaving
+// at least one frame in each
+func commonRoot(paths []PathView) int {
+	n := len(paths[0].Stack) - 1
+	for _, p := range paths[1:] {
+		n = min(n, len(p.Stack)-1)
+		for i := 0; i < n; i++ {
+			if p.Stack[i] != paths[0].Stack[i] {
+				n = i
+				break
+			}
+		}
+	}
+	return max(n, 0)
+}
+
+// shortenFrames strips the import path from each frame
+func shortenFrames(stack []string) []string {
+	result := make([]string, len(stack))
+	for i, frame := range stack {
+		result[i] = shortenFrame(frame)
+	}
+	return result
+}
+
+// shortenFrame turns "github.com/a/b/pkg.(*T).M" into "pkg.(*T).M". Type
+// parameters in brackets are left alone.
+func shortenFrame(frame string) string {
+	name := frame
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return frame[i+1:]
+	}
+	return frame
+}
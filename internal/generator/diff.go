@@ -96,8 +96,10 @@ func (g *DiffGenerator) prepareTemplateData() map[string]interface{} {
 		diffs = diffs[:g.topN]
 	}
 
-	return map[string]interface{}{
+	data := map[string]interface{}{
 		"Type":         string(g.baseProfile.Type),
+		"ProfileType":  g.baseProfile.Type,
+		"Unit":         metricUnit(g.newProfile),
 		"BaseStats":    g.baseProfile.Stats,
 		"NewStats":     g.newProfile.Stats,
 		"BaseTotal":    g.baseProfile.TotalSamples,
@@ -108,6 +110,19 @@ func (g *DiffGenerator) prepareTemplateData() map[string]interface{} {
 		"FormatNumber": FormatNumber,
 		"FormatDelta":  FormatDelta,
 	}
+
+	// Goroutine growth is attributed to creation sites, which matter more
+	// than the leaf frames goroutines are parked in
+	if g.baseProfile.Type == parser.TypeGoroutine {
+		profiles := []*parser.Profile{g.baseProfile, g.newProfile}
+		leaks, sites := detectGoroutineLeaks(profiles, g.topN)
+		_, rateUnit := timeAxis(profiles)
+		data["GoroutineLeaks"] = leaks
+		data["CreationSites"] = sites
+		data["RateUnit"] = rateUnit
+	}
+
+	return data
 }
 
 // computeDiff computes the differences between the two profiles
@@ -207,34 +222,33 @@ func (g *DiffGenerator) getTemplate() (*template.Template, error) {
 | Metric | Base | New | Delta |
 |--------|------|------|-------|
 {{- if eq .Type "cpu" }}
-| Total CPU Time | {{ formatDuration .BaseTotal }} | {{ formatDuration .NewTotal }} | {{ formatDuration .TotalDelta }} |
+| Total CPU Time | {{ formatDuration .BaseTotal }} | {{ formatDuration .NewTotal }} | {{ formatValueDelta .ProfileType .TotalDelta }} |
 | Duration | {{ formatDuration .BaseStats.TotalDuration.Nanoseconds }} | {{ formatDuration .NewStats.TotalDuration.Nanoseconds }} | - |
 {{- else if eq .Type "heap" }}
-| Allocated Bytes | {{ FormatBytes .BaseStats.AllocBytes }} | {{ FormatBytes .NewStats.AllocBytes }} | {{ FormatDelta (subtract .NewStats.AllocBytes .BaseStats.AllocBytes) }} |
+| Allocated Bytes | {{ FormatBytes .BaseStats.AllocBytes }} | {{ FormatBytes .NewStats.AllocBytes }} | {{ formatValueDelta .ProfileType (subtract .NewStats.AllocBytes .BaseStats.AllocBytes) }} |
 | Allocated Objects | {{ FormatNumber .BaseStats.AllocObjects }} | {{ FormatNumber .NewStats.AllocObjects }} | {{ FormatDelta (subtract .NewStats.AllocObjects .BaseStats.AllocObjects) }} |
-| In-Use Bytes | {{ FormatBytes .BaseStats.InUseBytes }} | {{ FormatBytes .NewStats.InUseBytes }} | {{ FormatDelta (subtract .NewStats.InUseBytes .BaseStats.InUseBytes) }} |
+| In-Use Bytes | {{ FormatBytes .BaseStats.InUseBytes }} | {{ FormatBytes .NewStats.InUseBytes }} | {{ formatValueDelta .ProfileType (subtract .NewStats.InUseBytes .BaseStats.InUseBytes) }} |
 | In-Use Objects | {{ FormatNumber .BaseStats.InUseObjects }} | {{ FormatNumber .NewStats.InUseObjects }} | {{ FormatDelta (subtract .NewStats.InUseObjects .BaseStats.InUseObjects) }} |
 {{- else if eq .Type "goroutine" }}
 | Total Goroutines | {{ FormatNumber .BaseStats.TotalGoroutines }} | {{ FormatNumber .NewStats.TotalGoroutines }} | {{ FormatDelta (subtract .NewStats.TotalGoroutines .BaseStats.TotalGoroutines) }} |
 {{- else if eq .Type "mutex" }}
-| Contention Time | {{ formatDuration .BaseStats.TotalContentionTime }} | {{ formatDuration .NewStats.TotalContentionTime }} | {{ formatDuration (subtract .NewStats.TotalContentionTime .BaseStats.TotalContentionTime) }} |
+| Contention Time | {{ formatDuration .BaseStats.TotalContentionTime }} | {{ formatDuration .NewStats.TotalContentionTime }} | {{ formatValueDelta .ProfileType (subtract .NewStats.TotalContentionTime .BaseStats.TotalContentionTime) }} |
 | Total Waits | {{ FormatNumber .BaseStats.TotalWaits }} | {{ FormatNumber .NewStats.TotalWaits }} | {{ FormatDelta (subtract .NewStats.TotalWaits .BaseStats.TotalWaits) }} |
+{{- else }}
+| Total | {{ formatUnit .Unit .BaseTotal }} | {{ formatUnit .Unit .NewTotal }} | {{ formatUnitDelta .Unit .TotalDelta }} |
 {{- end }}
 
 ## Top Changed Functions
 
-| Rank | Function | Base | New | Flat Δ | Flat Δ% | Cum Δ | Cum Δ% |
-|------|----------|------|------|---------|---------|-------|--------|
+| Rank | Function | Location | Base Flat | Base Cum | New Flat | New Cum | Flat Δ | Flat Δ% | Cum Δ | Cum Δ% |
+|------|----------|----------|-----------|----------|----------|---------|--------|---------|-------|--------|
 {{- range $i, $d := .Diffs }}
-| {{ add $i 1 }} | ` + "`" + `{{ $d.Name }}` + "`" + ` | {{ template "base-val" $d }} | {{ template "new-val" $d }} | {{ FormatDelta $d.FlatDelta }} | {{ printf "%+.1f" $d.FlatDeltaPct }}% | {{ FormatDelta $d.CumDelta }} | {{ printf "%+.1f" $d.CumDeltaPct }}% |
+| {{ add $i 1 }} | ` + "`" + `{{ $d.Name }}` + "`" + ` | {{ formatLocation $d.File $d.Line }} | {{ if $d.IsNew }}-{{ else }}{{ formatUnit $.Unit $d.BaseFlat }}{{ end }} | {{ if $d.IsNew }}-{{ else }}{{ formatUnit $.Unit $d.BaseCum }}{{ end }} | {{ if $d.IsRemoved }}-{{ else }}{{ formatUnit $.Unit $d.NewFlat }}{{ end }} | {{ if $d.IsRemoved }}-{{ else }}{{ formatUnit $.Unit $d.NewCum }}{{ end }} | {{ formatUnitDelta $.Unit $d.FlatDelta }} | {{ printf "%+.1f" $d.FlatDeltaPct }}% | {{ formatUnitDelta $.Unit $d.CumDelta }} | {{ printf "%+.1f" $d.CumDeltaPct }}% |
 {{- end }}
+{{- if eq .Type "goroutine" }}
 
-{{- define "base-val" }}
-{{- if .IsRemoved }}{{ .BaseCum }}{{ else if .IsNew }}-{{ else }}{{ .BaseCum }}{{ end }}
-{{- end }}
-
-{{- define "new-val" }}
-{{- if .IsRemoved }}-{{ else if .IsNew }}{{ .NewCum }}{{ else }}{{ .NewCum }}{{ end }}
+## Growing Goroutines
+{{ template "goroutine-leaks" . }}
 {{- end }}
 
 ---
@@ -263,16 +277,22 @@ Focus on actionable insights to understand the performance change.
 `
 
 	funcs := template.FuncMap{
-		"add":             func(a, b int) int { return a + b },
-		"subtract":        func(a, b int64) int64 { return a - b },
-		"abs":             abs,
-		"FormatBytes":     FormatBytes,
-		"FormatNumber":    FormatNumber,
-		"FormatDelta":     FormatDelta,
-		"formatDuration":  FormatDuration,
+		"add":              func(a, b int) int { return a + b },
+		"subtract":         func(a, b int64) int64 { return a - b },
+		"abs":              abs,
+		"FormatBytes":      FormatBytes,
+		"FormatNumber":     FormatNumber,
+		"FormatDelta":      FormatDelta,
+		"formatDuration":   FormatDuration,
+		"formatValue":      FormatValue,
+		"formatValueDelta": FormatValueDelta,
+		"formatUnit":       FormatUnit,
+		"formatUnitDelta":  FormatUnitDelta,
+		"formatLocation":   FormatLocation,
+		"formatStack":      formatStack,
 	}
 
-	return template.New("diff").Funcs(funcs).Parse(tmpl)
+	return template.New("diff").Funcs(funcs).Parse(tmpl + goroutineLeaksTemplate)
 }
 
 // FormatDelta formats a delta value with + or - sign and formatting
@@ -295,6 +315,23 @@ func FormatDelta(delta int64) string {
 	return fmt.Sprintf("%s%.1fM", sign, float64(delta)/1_000_000)
 }
 
+// FormatValueDelta formats a delta value with + or - sign in the unit of the profile type
+func FormatValueDelta(profileType parser.ProfileType, delta int64) string {
+	return FormatUnitDelta(valueUnit(profileType), delta)
+}
+
+// FormatUnitDelta formats a delta value with + or - sign according to its pprof unit
+func FormatUnitDelta(unit string, delta int64) string {
+	if delta == 0 {
+		return "0"
+	}
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+	}
+	return sign + FormatUnit(unit, abs(delta))
+}
+
 func abs(n int64) int64 {
 	if n < 0 {
 		return -n
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// GenerateDOT generates a Graphviz DOT call graph of the profile, comparable
+// to `go tool pprof -dot`
+func (g *Generator) GenerateDOT() (string, error) {
+	if err := g.selectSampleType(); err != nil {
+		return "", err
+	}
+	return writeDOT(g.profile, g.buildCallGraph()), nil
+}
+
+// GenerateDOT generates a Graphviz DOT call graph of the new profile with
+// nodes and edges colored by change: red for growth, green for reduction
+func (g *DiffGenerator) GenerateDOT() (string, error) {
+	graph := buildCallGraph(g.baseProfile, g.newProfile, DefaultNodeFraction, DefaultEdgeFraction)
+	return writeDOT(g.newProfile, graph), nil
+}
+
+// writeDOT renders a call graph of a profile in the DOT language
+func writeDOT(profile *parser.Profile, graph CallGraph) string {
+	profileType, total, unit := profile.Type, profile.TotalSamples, metricUnit(profile)
+	var sb strings.Builder
+	title := fmt.Sprintf("%s profile", profileType)
+	if graph.Diff {
+		title += " diff"
+	}
+
+	fmt.Fprintf(&sb, "digraph %s {\n", dotQuote(title))
+	sb.WriteString("node [style=filled fillcolor=\"#f8f8f8\"]\n")
+
+	legend := fmt.Sprintf("Type: %s\\lTotal: %s\\lShowing %d nodes and %d edges\\l", profileType, FormatUnit(unit, total), len(graph.Nodes), len(graph.Edges))
+	if graph.Diff {
+		legend += "Red: grew, green: shrank\\l"
+	}
+	fmt.Fprintf(&sb, "subgraph cluster_L { \"legend\" [shape=box fontsize=16 label=\"%s\"] }\n", legend)
+
+	var maxFlat, maxEdge int64
+	for _, n := range graph.Nodes {
+		maxFlat = max(maxFlat, n.Flat)
+	}
+	for _, e := range graph.Edges {
+		maxEdge = max(maxEdge, e.Weight, abs(e.Delta))
+	}
+
+	for _, n := range graph.Nodes {
+		label := fmt.Sprintf("%s\n%s (%.2f%%)\nof %s (%.2f%%)", n.Label,
+			FormatUnit(unit, n.Flat), pctOf(n.Flat, total),
+			FormatUnit(unit, n.Cum), n.CumPct)
+		score := n.CumPct / 100
+		if graph.Diff {
+			label += "\n" + FormatUnitDelta(unit, n.Delta)
+			score = signedFraction(n.Delta, total)
+		}
+		fill, border := dotColors(score)
+		fontSize := 10.0
+		if maxFlat > 0 {
+			fontSize += 14 * math.Sqrt(float64(n.Flat)/float64(maxFlat))
+		}
+		fmt.Fprintf(&sb, "%s [label=%s tooltip=%s fontsize=%.0f shape=box color=%q fillcolor=%q]\n",
+			n.ID, dotQuote(label), dotQuote(n.Name), fontSize, border, fill)
+	}
+
+	for _, e := range graph.Edges {
+		label := " " + FormatUnit(unit, e.Weight)
+		score := e.Pct / 100
+		if graph.Diff {
+			label += " (" + FormatUnitDelta(unit, e.Delta) + ")"
+			score = signedFraction(e.Delta, total)
+		}
+		_, color := dotColors(score)
+		penWidth := 1.0
+		if maxEdge > 0 {
+			penWidth += 4 * float64(max(e.Weight, abs(e.Delta))) / float64(maxEdge)
+		}
+		fmt.Fprintf(&sb, "%s -> %s [label=%s weight=%d penwidth=%.1f color=%q]\n",
+			e.From, e.To, dotQuote(label), max(int(e.Pct), 1), penWidth, color)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotColors returns fill and border colors for a score in [-1, 1]:
+// positive scores shade red, negative scores green and zero grey
+func dotColors(score float64) (fill, border string) {
+	shade := math.Min(math.Sqrt(math.Abs(score)), 1)
+	light := func(max float64) int { return int(max - shade*(max-64)) }
+	switch {
+	case score > 0:
+		return fmt.Sprintf("#ff%02x%02x", light(240), light(240)), fmt.Sprintf("#%02x%02x%02x", 204, light(204)/2, light(204)/2)
+	case score < 0:
+		return fmt.Sprintf("#%02xff%02x", light(240), light(240)), fmt.Sprintf("#%02x%02x%02x", light(204)/2, 153, light(204)/2)
+	default:
+		return "#f8f8f8", "#b2b2b2"
+	}
+}
+
+// signedFraction returns v as a fraction of total, clamped to [-1, 1]
+func signedFraction(v, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Max(-1, math.Min(1, float64(v)/float64(total)))
+}
+
+// pctOf returns v as a percentage of total
+func pctOf(v, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(v) / float64(total) * 100
+}
+
+// dotQuote quotes a string as a DOT ID, escaping quotes, backslashes and
+// newlines
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Text flame graph dimensions
+const (
+	flameWidth    = 100 // Characters per row
+	flameMaxDepth = 16  // Rows below the root before the graph is cut off
+	flameMinWidth = 3   // Narrowest frame drawn, in characters
+	flameMaxCut   = 5   // Cut frames named below the graph
+)
+
+// textFlameGraph renders the profile's sample stacks as a fixed-width icicle
+// chart, root at the top. Each frame is drawn as "[name pct%]" with a width
+// proportional to its weight; frames narrower than flameMinWidth characters
+// are left out. Stacks are compacted first so the rows are spent on
+// application frames: runtime and standard library root frames are elided,
+// recursion is collapsed and the trunk shared by every stack is folded into
+// a note. The returned notes also name the frames cut off below
+// flameMaxDepth.
+func (g *Generator) textFlameGraph() (lines, notes []string) {
+	root, trunk, elided := g.compactFlameTree()
+	if root.Value == 0 {
+		return nil, nil
+	}
+
+	var rows [][]rune
+	var cut []*flameNode
+	var draw func(n *flameNode, depth, start, end int)
+	draw = func(n *flameNode, depth, start, end int) {
+		if depth > flameMaxDepth {
+			cut = append(cut, n)
+			return
+		}
+		for len(rows) <= depth {
+			rows = append(rows, []rune(strings.Repeat(" ", flameWidth)))
+		}
+		pct := float64(n.Value) / float64(root.Value) * 100
+		copy(rows[depth][start:end], flameBox(shortenFrame(n.Name), pct, end-start))
+
+		// Children are placed by cumulative weight so rounding never drifts
+		var offset int64
+		for _, c := range n.Children {
+			from := start + int(float64(end-start)*float64(offset)/float64(n.Value)+0.5)
+			offset += c.Value
+			to := start + int(float64(end-start)*float64(offset)/float64(n.Value)+0.5)
+			if to-from >= flameMinWidth {
+				draw(c, depth+1, from, to)
+			}
+		}
+	}
+	draw(root, 0, 0, flameWidth)
+
+	lines = make([]string, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, strings.TrimRight(string(row), " "))
+	}
+
+	if elided {
+		notes = append(notes, "Runtime and standard library frames at the root of each stack are elided.")
+	}
+	if len(trunk) > 0 {
+		notes = append(notes, "Frames shared by every stack, below root: "+formatFrames(shortenFrames(trunk))+".")
+	}
+	if len(cut) > 0 {
+		notes = append(notes, flameCutNote(cut, root.Value))
+	}
+	return lines, notes
+}
+
+// compactFlameTree builds the flame tree from compacted sample stacks and
+// folds the trunk of frames shared by every stack into the root. It returns
+// the tree, the folded frames and whether any runtime root frames were
+// elided.
+func (g *Generator) compactFlameTree() (*flameNode, []string, bool) {
+	tree := newTreeNode()
+	var total int64
+	elided := false
+	for _, s := range g.profile.Samples {
+		n := runtimePrefix(s.Stack)
+		elided = elided || n > 0
+		tree.add(collapseRecursion(s.Stack[n:]), s.Value)
+		total += s.Value
+	}
+	tree.name = "root"
+	tree.weight = total
+	root := toFlameNode(tree)
+
+	// A single child carrying all of the root's weight adds a row without
+	// adding information
+	var trunk []string
+	for len(root.Children) == 1 && len(root.Children[0].Children) > 0 && childrenValue(root.Children[0]) == root.Value {
+		trunk = append(trunk, root.Children[0].Name)
+		root.Children = root.Children[0].Children
+	}
+	return root, trunk, elided
+}
+
+// childrenValue returns the total weight of a node's children
+func childrenValue(n *flameNode) int64 {
+	var total int64
+	for _, c := range n.Children {
+		total += c.Value
+	}
+	return total
+}
+
+// flameCutNote names the heaviest frames cut off below flameMaxDepth, with
+// the hottest leaf below each
+func flameCutNote(cut []*flameNode, total int64) string {
+	sort.SliceStable(cut, func(i, j int) bool { return cut[i].Value > cut[j].Value })
+	var parts []string
+	for _, n := range cut[:min(len(cut), flameMaxCut)] {
+		part := fmt.Sprintf("`%s` (%.1f%%", shortenFrame(n.Name), float64(n.Value)/float64(total)*100)
+		if leaf := hottestLeaf(n); leaf != n {
+			part += fmt.Sprintf(", hottest leaf `%s`", shortenFrame(leaf.Name))
+		}
+		parts = append(parts, part+")")
+	}
+	note := fmt.Sprintf("Stacks deeper than %d levels are cut off at: %s", flameMaxDepth, strings.Join(parts, ", "))
+	if len(cut) > flameMaxCut {
+		note += fmt.Sprintf(" and %d more", len(cut)-flameMaxCut)
+	}
+	return note + "."
+}
+
+// hottestLeaf follows the heaviest child down to a leaf
+func hottestLeaf(n *flameNode) *flameNode {
+	for len(n.Children) > 0 {
+		n = n.Children[0]
+	}
+	return n
+}
+
+// formatFrames renders frames as "`a` → `b`"
+func formatFrames(frames []string) string {
+	quoted := make([]string, len(frames))
+	for i, f := range frames {
+		quoted[i] = "`" + f + "`"
+	}
+	return strings.Join(quoted, " → ")
+}
+
+// flameBox draws a frame of the given width as "[--- name pct% ---]",
+// shortening the label to fit
+func flameBox(name string, pct float64, width int) []rune {
+	inner := width - 2
+	label := []rune(fmt.Sprintf(" %s %.1f%% ", name, pct))
+	if len(label) > inner {
+		label = []rune(" " + name + " ")
+	}
+	if len(label) > inner {
+		label = append(label[:max(inner-1, 0)], '…')
+	}
+	if inner <= 1 {
+		label = nil
+	}
+
+	box := make([]rune, 0, width)
+	box = append(box, '[')
+	left := (inner - len(label)) / 2
+	box = append(box, []rune(strings.Repeat("-", left))...)
+	box = append(box, label...)
+	box = append(box, []rune(strings.Repeat("-", inner-left-len(label)))...)
+	return append(box, ']')
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GenerateFolded generates the profile in Brendan Gregg's collapsed-stack
+// format: one "root;caller;leaf value" line per distinct stack, as read by
+// flamegraph.pl, inferno and speedscope. Values are the report's metric,
+// the sample type selected with WithSampleIndex or the profile type's usual
+// one, in the same unit as the markdown report: time in nanoseconds.
+func (g *Generator) GenerateFolded() (string, error) {
+	if err := g.selectSampleType(); err != nil {
+		return "", err
+	}
+
+	weights := make(map[string]int64)
+	for _, s := range g.profile.Samples {
+		if s.Value == 0 || len(s.Stack) == 0 {
+			continue
+		}
+		frames := make([]string, len(s.Stack))
+		for i, frame := range s.Stack {
+			frames[i] = strings.ReplaceAll(frame, ";", ":")
+		}
+		weights[strings.Join(frames, ";")] += s.Value
+	}
+
+	stacks := make([]string, 0, len(weights))
+	for stack := range weights {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	var sb strings.Builder
+	for _, stack := range stacks {
+		fmt.Fprintf(&sb, "%s %d\n", stack, weights[stack])
+	}
+	return sb.String(), nil
+}
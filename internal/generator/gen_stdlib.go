@@ -0,0 +1,48 @@
+//go:build ignore
+// +build ignore
+
+// gen_stdlib writes stdlib.go, the list of standard library packages used to
+// recognize runtime and standard library frames. Run it with go generate
+// after a Go release adds packages.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func main() {
+	out, err := exec.Command("go", "list", "std").Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list standard library packages: %v\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_stdlib.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package generator\n\n")
+	buf.WriteString("// stdlibPackages is the set of standard library import paths, including\n")
+	buf.WriteString("// internal and vendored packages, whose frames appear in Go profiles\n")
+	buf.WriteString("var stdlibPackages = map[string]bool{\n")
+	for _, pkg := range strings.Fields(string(out)) {
+		if pkg == "cmd" || strings.HasPrefix(pkg, "cmd/") {
+			continue
+		}
+		fmt.Fprintf(&buf, "\t%q: true,\n", pkg)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to format stdlib.go: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("stdlib.go", src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write stdlib.go: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/alingse/go-pprof-md/internal/parser"
@@ -13,6 +14,18 @@ type Generator struct {
 	profile        *parser.Profile
 	topN           int
 	includeAIPrompt bool
+	maxTokens      int
+	maxPaths       int
+	pathThreshold  float64
+	compaction     StackCompaction
+	includeCallTree bool
+	nodeFraction   float64
+	includePeek    bool
+	includeMermaid bool
+	edgeFraction   float64
+	sampleIndex    string
+	includeFlame   bool
+	sourceDir      string
 }
 
 // NewGenerator creates a new markdown generator
@@ -21,6 +34,8 @@ func NewGenerator(profile *parser.Profile, opts ...Option) *Generator {
 		profile:        profile,
 		topN:           20,
 		includeAIPrompt: true,
+		nodeFraction:   DefaultNodeFraction,
+		edgeFraction:   DefaultEdgeFraction,
 	}
 
 	for _, opt := range opts {
@@ -47,14 +62,123 @@ func WithAIPrompt(enable bool) Option {
 	}
 }
 
+// WithMaxTokens limits the estimated token count of the report. Call paths,
+// stack frames, optional sections and low-ranked functions are dropped until
+// the report fits, and the report notes what was omitted. Zero disables the
+// limit.
+func WithMaxTokens(n int) Option {
+	return func(g *Generator) {
+		g.maxTokens = n
+	}
+}
+
+// WithMaxPaths limits the number of call paths shown per function.
+// Zero shows all call paths.
+func WithMaxPaths(n int) Option {
+	return func(g *Generator) {
+		g.maxPaths = n
+	}
+}
+
+// WithPathThreshold hides call paths whose weight is below pct percent of
+// the function's flat value
+func WithPathThreshold(pct float64) Option {
+	return func(g *Generator) {
+		g.pathThreshold = pct
+	}
+}
+
+// WithStackCompaction sets how call path stacks are compacted
+func WithStackCompaction(c StackCompaction) Option {
+	return func(g *Generator) {
+		g.compaction = c
+	}
+}
+
+// WithCallTree adds a top-down call tree and an inverted caller tree for
+// each top function
+func WithCallTree(enable bool) Option {
+	return func(g *Generator) {
+		g.includeCallTree = enable
+	}
+}
+
+// WithNodeFraction hides call tree and call graph nodes whose weight is
+// below this fraction of the profile total
+func WithNodeFraction(f float64) Option {
+	return func(g *Generator) {
+		g.nodeFraction = f
+	}
+}
+
+// WithPeek adds tables of the immediate callers and callees of each top
+// function
+func WithPeek(enable bool) Option {
+	return func(g *Generator) {
+		g.includePeek = enable
+	}
+}
+
+// WithMermaid adds a Mermaid flowchart of the hot call graph
+func WithMermaid(enable bool) Option {
+	return func(g *Generator) {
+		g.includeMermaid = enable
+	}
+}
+
+// WithEdgeFraction hides call graph edges whose weight is below this
+// fraction of the profile total
+func WithEdgeFraction(f float64) Option {
+	return func(g *Generator) {
+		g.edgeFraction = f
+	}
+}
+
+// WithSampleIndex selects the sample type, such as "inuse_space", reported
+// as the primary metric. Empty uses the profile type's usual metric.
+func WithSampleIndex(name string) Option {
+	return func(g *Generator) {
+		g.sampleIndex = name
+	}
+}
+
+// WithFlameGraph adds a text flame graph of the sample stacks
+func WithFlameGraph(enable bool) Option {
+	return func(g *Generator) {
+		g.includeFlame = enable
+	}
+}
+
+// WithSourceDir sets the directory searched for source files that are not
+// at the path recorded in the profile, for the source listings of split
+// reports
+func WithSourceDir(dir string) Option {
+	return func(g *Generator) {
+		g.sourceDir = dir
+	}
+}
+
 // Generate generates markdown from the profile
 func (g *Generator) Generate() (string, error) {
+	if err := g.selectSampleType(); err != nil {
+		return "", err
+	}
+
 	tmpl, err := g.getTemplate()
 	if err != nil {
 		return "", fmt.Errorf("failed to get template: %w", err)
 	}
 
-	data := g.prepareTemplateData()
+	if g.maxTokens > 0 {
+		return g.fitTokenBudget(tmpl)
+	}
+	layout := g.defaultLayout()
+	return g.render(tmpl, layout, g.buildSections(layout))
+}
+
+// render executes the template with the given layout
+func (g *Generator) render(tmpl *template.Template, layout reportLayout, sections *reportSections) (string, error) {
+	data := g.prepareTemplateData(layout, sections)
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
@@ -64,29 +188,53 @@ func (g *Generator) Generate() (string, error) {
 	return buf.String(), nil
 }
 
-// prepareTemplateData prepares data for template rendering
-func (g *Generator) prepareTemplateData() map[string]interface{} {
-	// Limit functions to top N
-	functions := g.profile.Functions
-	if len(functions) > g.topN {
-		functions = functions[:g.topN]
-	}
+// prepareTemplateData prepares data for template rendering. Optional
+// sections are taken from sections when layout includes them.
+func (g *Generator) prepareTemplateData(layout reportLayout, sections *reportSections) map[string]interface{} {
+	functions, omitted := g.applyLayout(layout)
 
-	return map[string]interface{}{
+	data := map[string]interface{}{
 		"Type":             string(g.profile.Type),
 		"Stats":            g.profile.Stats,
+		"Trace":            g.profile.Trace,
+		"SampleTypes":      g.profile.SampleTypes,
 		"Functions":        functions,
+		"Omitted":          omitted,
+		"MaxTokens":        g.maxTokens,
 		"TotalSamples":     g.profile.TotalSamples,
 		"IncludeAIPrompt":  g.includeAIPrompt,
+		"IncludePeek":      layout.peek,
 		"AIAnalysisPrompt": g.getAIAnalysisPrompt(),
 	}
+
+	if layout.tree {
+		data["CallTree"] = sections.callTree
+		for i := range functions {
+			functions[i].CallerTree = sections.callerTrees[functions[i].Name]
+		}
+	}
+
+	if layout.flame {
+		data["FlameGraph"], data["FlameNotes"] = sections.flame, sections.flameNotes
+	}
+	if layout.mermaid {
+		data["CallGraph"] = sections.callGraph
+	}
+
+	// The leaf of most goroutines is runtime.gopark, so rank by the site
+	// that started them as well
+	if g.profile.Type == parser.TypeGoroutine {
+		data["CreationSites"] = groupByCreationSite(g.profile, layout.topN)
+	}
+
+	return data
 }
 
 // getTemplate returns the appropriate template based on profile type
 func (g *Generator) getTemplate() (*template.Template, error) {
 	// Build full template with main template + stats template
 	mainTmpl := g.getMainTemplateContent()
-	statsTmpl := getStatsTemplate(g.profile.Type)
+	statsTmpl := getStatsTemplate(g.profile.Type) + metricTemplate(g.metricHeader(), metricUnit(g.profile))
 	fullTmpl := statsTmpl + "\n" + mainTmpl
 
 	// Create template with functions
@@ -94,30 +242,109 @@ func (g *Generator) getTemplate() (*template.Template, error) {
 	return tmpl.Parse(fullTmpl)
 }
 
-// getMainTemplateContent returns the main template content
+// getMainTemplateContent returns the main template content. Each section is
+// a named template so split reports can render sections on their own.
 func (g *Generator) getMainTemplateContent() string {
 	return `# {{ .Type }} Profile Analysis
+{{- template "budget-note" . }}
+{{- template "summary" . }}
+{{- template "top-functions-header" . }}
+{{- range $fn := .Functions }}
+{{- template "function-row" $fn }}
+{{- end }}
+{{- template "creation-sites" . }}
+{{- template "flame-graph" . }}
+{{- range $fn := .Functions }}
+{{- template "function-paths" $fn }}
+{{- end }}
+{{- template "mermaid" . }}
+{{- template "peek" . }}
+{{- template "call-tree" . }}
+{{- template "caller-trees" . }}
+
+{{- if .IncludeAIPrompt }}
+
+{{ .AIAnalysisPrompt }}
+{{- end }}
+
+{{- define "budget-note" }}
+{{- if .Omitted.Any }}
+
+> **Note:** This report was trimmed to fit a budget of about {{ .MaxTokens }} tokens. Omitted: {{ .Omitted }}. Ask for a larger budget if that detail matters.
+{{- end }}
+{{- end }}
+
+{{- define "summary" }}
 
 ## Summary Statistics
 
 {{- template "stats" . }}
+{{- end }}
+
+{{- define "top-functions-header" }}
 
 ## Top {{ .Type }} Functions
 
 | Rank | Function | File | {{ template "metric-header" .Type }} | % of Total | Sum % | Cumulative | Cumulative % |
 |------|----------|------|-------|------------|-------|------------|--------------|
-{{- range $i, $fn := .Functions }}
-| {{ add $i 1 }} | ` + "`" + `{{ $fn.Name }}` + "`" + ` | {{ $fn.File }}:{{ $fn.Line }} | {{ template "metric-value" $fn }} | {{ printf "%.2f" $fn.FlatPct }}% | {{ printf "%.2f" $fn.SumPct }}% | {{ template "metric-cum" $fn }} | {{ printf "%.2f" $fn.CumPct }}% |
 {{- end }}
 
-{{- range $fn := .Functions }}
-{{- if ne (len $fn.CallPaths) 0 }}
+{{- define "function-row" }}
+| {{ .Rank }} | ` + "`" + `{{ .Name }}` + "`" + ` | {{ .File }}:{{ .Line }} | {{ template "metric-value" . }} | {{ printf "%.2f" .FlatPct }}% | {{ printf "%.2f" .SumPct }}% | {{ template "metric-cum" . }} | {{ printf "%.2f" .CumPct }}% |
+{{- end }}
+
+{{- define "creation-sites" }}
+{{- if .CreationSites }}
+
+## Goroutines by Creation Site
+
+Sites are the ` + "`" + `created by` + "`" + ` lines of a goroutine dump. Goroutines without one, and all goroutines of a binary profile, are grouped by the function they run, marked _(entry function)_.
+
+| Rank | Creation Site | Goroutines | % of Total | Stacks | Waiting In |
+|------|---------------|------------|------------|--------|------------|
+{{- range $i, $site := .CreationSites }}
+| {{ add $i 1 }} | ` + "`" + `{{ $site.CreatedBy }}` + "`" + `{{ if $site.Inferred }} _(entry function)_{{ end }} | {{ $site.Count }} | {{ printf "%.2f" $site.Pct }}% | {{ $site.Stacks }} | {{ range $j, $w := $site.WaitingIn }}{{ if $j }}, {{ end }}` + "`" + `{{ $w.Name }}` + "`" + ` ({{ $w.Count }}){{ end }} |
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "flame-graph" }}
+{{- if .FlameGraph }}
+
+## Flame Graph
+
+Icicle chart of all sampled stacks: callers above callees, each frame as wide as its share of the total.
+
+` + "```" + `text
+{{- range .FlameGraph }}
+{{ . }}
+{{- end }}
+` + "```" + `
+{{- range .FlameNotes }}
+
+_{{ . }}_
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "function-paths" }}
+{{- if ne (len .CallPaths) 0 }}
 
-### {{ $fn.Name }}
+### {{ .Name }}
+{{- if .CommonRoot }}
 
-{{- range $pi, $path := $fn.CallPaths }}
+_All paths start with {{ len .CommonRoot }} common frames, ending at_ ` + "`" + `{{ last .CommonRoot }}` + "`" + `
+{{- end }}
+
+{{- range $pi, $path := .CallPaths }}
 
-**Call Path #{{ add $pi 1 }}** (weight: {{ $path.Weight }})
+**Call Path #{{ add $pi 1 }}** ({{ template "metric-weight" $path.Weight }}, {{ printf "%.2f" $path.Pct }}%)
+{{- if $path.ElidedFrames }}
+    … {{ $path.ElidedFrames }} runtime/stdlib frames elided
+{{- end }}
+{{- if $path.OmittedFrames }}
+    … {{ $path.OmittedFrames }} outer frames omitted
+{{- end }}
 {{- range $i, $call := $path.Stack }}
 {{- if eq $i 0 }}
   → {{ $call }}
@@ -126,15 +353,119 @@ func (g *Generator) getMainTemplateContent() string {
 {{- end }}
 {{- end }}
 
+{{- end }}
+{{- if .OmittedPaths }}
+
+_{{ pluralize .OmittedPaths "other path" }} ({{ template "metric-weight" .OmittedWeight }}, {{ printf "%.2f" .OmittedPct }}%)_
 {{- end }}
 
 {{- end }}
 {{- end }}
 
-{{- if .IncludeAIPrompt }}
+{{- define "mermaid" }}
+{{- with .CallGraph }}
 
-{{ .AIAnalysisPrompt }}
+## Call Graph
+
+Functions above the node fraction, labelled with their cumulative share. Bold edges carry at least 10% of the total.
+
+` + "```" + `mermaid
+flowchart TD
+{{- range .Nodes }}
+    {{ .ID }}["{{ mermaidLabel .Label }}<br/>{{ printf "%.2f" .CumPct }}% cum"]:::{{ .Heat }}
+{{- end }}
+{{- range .Edges }}
+    {{ .From }} {{ if .Heavy }}==>{{ else }}-->{{ end }}|"{{ template "metric-weight" .Weight }}"| {{ .To }}
+{{- end }}
+    classDef hot fill:#f8d0d0,stroke:#c00000
+    classDef warm fill:#fbe8c8,stroke:#d08000
+    classDef cold fill:#eeeeee,stroke:#999999
+` + "```" + `
+{{- end }}
+{{- end }}
+
+{{- define "peek" }}
+{{- if .IncludePeek }}
+
+## Callers and Callees
+
+Weight flowing through each edge, as a percentage of the function's cumulative value.
+{{- range $fn := .Functions }}
+{{- template "function-peek" $fn }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "function-peek" }}
+
+### ` + "`" + `{{ .Name }}` + "`" + `
+
+Flat {{ template "metric-weight" .Flat }}, cumulative {{ template "metric-weight" .Cum }}
+
+| Caller | Weight | % |
+|--------|--------|---|
+{{- range $e := head .Callers 10 }}
+| ` + "`" + `{{ $e.Name }}` + "`" + ` | {{ template "metric-weight" $e.Weight }} | {{ printf "%.2f" (pct $e.Weight $.Cum) }}% |
+{{- else }}
+| _(root)_ | | |
 {{- end }}
+{{- if gt (len .Callers) 10 }}
+| _{{ sub (len .Callers) 10 }} more_ | | |
+{{- end }}
+
+| Callee | Weight | % |
+|--------|--------|---|
+{{- range $e := head .Callees 10 }}
+| ` + "`" + `{{ $e.Name }}` + "`" + ` | {{ template "metric-weight" $e.Weight }} | {{ printf "%.2f" (pct $e.Weight $.Cum) }}% |
+{{- else }}
+| _(none, all flat)_ | | |
+{{- end }}
+{{- if gt (len .Callees) 10 }}
+| _{{ sub (len .Callees) 10 }} more_ | | |
+{{- end }}
+{{- end }}
+
+{{- define "call-tree" }}
+{{- if .CallTree }}
+
+## Call Tree
+
+Top-down from the roots. Each node shows the percentage of the total that passes through it.
+
+` + "```" + `
+{{- range .CallTree }}
+{{ template "tree-row" . }}
+{{- end }}
+` + "```" + `
+{{- end }}
+{{- end }}
+
+{{- define "caller-trees" }}
+{{- if .CallTree }}
+
+## Caller Trees
+
+Bottom-up from each top function: each level lists the callers of the level above.
+{{- range $fn := .Functions }}
+{{- template "caller-tree" $fn }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "caller-tree" }}
+{{- if .CallerTree }}
+
+### Callers of ` + "`" + `{{ .Name }}` + "`" + `
+
+` + "```" + `
+{{- range .CallerTree }}
+{{ template "tree-row" . }}
+{{- end }}
+` + "```" + `
+{{- end }}
+{{- end }}
+
+{{- define "tree-row" }}{{ printf "%6.2f%%" .Pct }}  {{ indent .Depth }}{{ .Name }} ({{ template "metric-weight" .Weight }}){{ end }}
 `
 }
 
@@ -147,9 +478,40 @@ func getTemplateFuncs() template.FuncMap {
 		"formatBytes": FormatBytes,
 		"formatDuration": FormatDuration,
 		"formatNumber": FormatNumber,
+		"sub": func(a, b int) int {
+			return a - b
+		},
+		"pct": func(v, total int64) float64 {
+			if total == 0 {
+				return 0
+			}
+			return float64(v) / float64(total) * 100
+		},
+		"head": func(edges []parser.Edge, n int) []parser.Edge {
+			if len(edges) > n {
+				return edges[:n]
+			}
+			return edges
+		},
+		"mermaidLabel": mermaidLabel,
+		"indent": func(depth int) string {
+			return strings.Repeat("  ", depth)
+		},
+		"last": func(s []string) string {
+			return s[len(s)-1]
+		},
+		"pluralize": pluralize,
 	}
 }
 
+// pluralize formats a count followed by noun, adding an "s" unless n is 1
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 // getAIAnalysisPrompt returns AI-friendly analysis prompt
 func (g *Generator) getAIAnalysisPrompt() string {
 	return getAIPrompt(g.profile.Type)
@@ -162,13 +524,9 @@ func getStatsTemplate(profileType parser.ProfileType) string {
 		return `
 {{- define "stats" }}
 - **Profile Duration:** {{ formatDuration .Stats.TotalDuration.Nanoseconds }}
-- **Total CPU Time:** {{ formatDuration .TotalSamples }}
+- **Total {{ template "metric-header" }}:** {{ template "metric-weight" .TotalSamples }}
 - **Sample Rate:** {{ .Stats.SampleRate }} Hz
 {{- end }}
-
-{{- define "metric-header" }}CPU Time{{ end }}
-{{- define "metric-value" }}{{ formatDuration .Flat }}{{ end }}
-{{- define "metric-cum" }}{{ formatDuration .Cum }}{{ end }}
 `
 
 	case parser.TypeHeap:
@@ -179,10 +537,6 @@ func getStatsTemplate(profileType parser.ProfileType) string {
 - **In-Use Objects:** {{ formatNumber .Stats.InUseObjects }}
 - **In-Use Bytes:** {{ formatBytes .Stats.InUseBytes }}
 {{- end }}
-
-{{- define "metric-header" }}Allocated Bytes{{ end }}
-{{- define "metric-value" }}{{ formatBytes .Flat }}{{ end }}
-{{- define "metric-cum" }}{{ formatBytes .Cum }}{{ end }}
 `
 
 	case parser.TypeGoroutine:
@@ -191,10 +545,6 @@ func getStatsTemplate(profileType parser.ProfileType) string {
 - **Total Goroutines:** {{ formatNumber .Stats.TotalGoroutines }}
 - **Current Goroutines:** {{ .TotalSamples }}
 {{- end }}
-
-{{- define "metric-header" }}Goroutines{{ end }}
-{{- define "metric-value" }}{{ .Flat }}{{ end }}
-{{- define "metric-cum" }}{{ .Cum }}{{ end }}
 `
 
 	case parser.TypeMutex:
@@ -203,21 +553,108 @@ func getStatsTemplate(profileType parser.ProfileType) string {
 - **Total Contention Time:** {{ formatDuration .Stats.TotalContentionTime }}
 - **Total Waits:** {{ formatNumber .Stats.TotalWaits }}
 {{- end }}
+`
 
-{{- define "metric-header" }}Contention Time{{ end }}
-{{- define "metric-value" }}{{ formatDuration .Flat }}{{ end }}
-{{- define "metric-cum" }}{{ formatDuration .Cum }}{{ end }}
+	case parser.TypeSched, parser.TypeSyscall, parser.TypeNet, parser.TypeSync:
+		return `
+{{- define "stats" }}
+- **Total {{ template "metric-header" }}:** {{ formatDuration .Stats.TotalContentionTime }}
+- **Events:** {{ formatNumber .Stats.TotalWaits }}
+{{- with .Trace }}
+- **Trace Duration:** {{ formatDuration .Duration.Nanoseconds }}
+- **GOMAXPROCS:** {{ .GOMAXPROCS }}
+- **Goroutines:** {{ .Goroutines }}
+- **Proc Utilization:** {{ printf "%.1f" .ProcUtilization }}% of GOMAXPROCS × duration spent running goroutines
+- **GC Cycles:** {{ .GCCycles }}
+- **Stop-the-World Pauses:** {{ .GCPauses }} (total {{ formatDuration .GCPauseTotal.Nanoseconds }}, max {{ formatDuration .GCPauseMax.Nanoseconds }})
+{{- end }}
+{{- end }}
 `
 
 	default:
 		return `
 {{- define "stats" }}
-- **Total Samples:** {{ .TotalSamples }}
+- **Total {{ template "metric-header" }}:** {{ template "metric-weight" .TotalSamples }}
+{{- if .SampleTypes }}
+- **Sample Types:** {{ range $i, $st := .SampleTypes }}{{ if $i }}, {{ end }}` + "`" + `{{ $st.Type }}` + "`" + ` ({{ $st.Unit }}){{ end }}
+{{- end }}
 {{- end }}
-
-{{- define "metric-header" }}Value{{ end }}
-{{- define "metric-value" }}{{ .Flat }}{{ end }}
-{{- define "metric-cum" }}{{ .Cum }}{{ end }}
 `
 	}
 }
+
+// metricTemplate returns the templates naming the primary metric and
+// formatting its values in unit
+func metricTemplate(header, unit string) string {
+	format := ""
+	switch unit {
+	case "nanoseconds":
+		format = "formatDuration "
+	case "bytes":
+		format = "formatBytes "
+	}
+	return fmt.Sprintf(`
+{{- define "metric-header" }}{{ %q }}{{ end }}
+{{- define "metric-value" }}{{ %s.Flat }}{{ end }}
+{{- define "metric-cum" }}{{ %s.Cum }}{{ end }}
+{{- define "metric-weight" }}{{ %s. }}{{ end }}
+`, header, format, format, format)
+}
+
+// metricHeaders names the metric of common sample types
+var metricHeaders = map[string]string{
+	"cpu":           "CPU Time",
+	"samples":       "Samples",
+	"alloc_space":   "Allocated Bytes",
+	"alloc_objects": "Allocated Objects",
+	"inuse_space":   "In-Use Bytes",
+	"inuse_objects": "In-Use Objects",
+	"goroutine":     "Goroutines",
+	"goroutines":    "Goroutines",
+	"contentions":   "Contentions",
+	"delay":         "Contention Time",
+	"lock_duration": "Contention Time",
+}
+
+// metricHeader returns the name of the primary metric: the usual name for
+// the profile type, or the name of the selected or custom sample type
+func (g *Generator) metricHeader() string {
+	if g.sampleIndex == "" {
+		switch g.profile.Type {
+		case parser.TypeCPU:
+			return "CPU Time"
+		case parser.TypeHeap:
+			return "Allocated Bytes"
+		case parser.TypeGoroutine:
+			return "Goroutines"
+		case parser.TypeMutex:
+			return "Contention Time"
+		case parser.TypeSched:
+			return "Scheduler Latency"
+		case parser.TypeSyscall:
+			return "Syscall Time"
+		case parser.TypeNet:
+			return "Network Wait"
+		case parser.TypeSync:
+			return "Sync Wait"
+		}
+	}
+	if header, ok := metricHeaders[g.profile.Metric.Type]; ok {
+		return header
+	}
+	if g.profile.Metric.Type != "" {
+		return g.profile.Metric.Type
+	}
+	return "Value"
+}
+
+// selectSampleType makes the sample type chosen with WithSampleIndex the
+// primary metric of the report
+func (g *Generator) selectSampleType() error {
+	profile, err := g.profile.SelectSampleType(g.sampleIndex)
+	if err != nil {
+		return err
+	}
+	g.profile = profile
+	return nil
+}
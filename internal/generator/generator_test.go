@@ -1,6 +1,11 @@
 package generator
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -268,6 +273,158 @@ func TestGenerateMutexProfile(t *testing.T) {
 	}
 }
 
+// TestGenerateTraceProfile tests the summary of profiles derived from
+// execution traces
+func TestGenerateTraceProfile(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeSync,
+		TotalSamples: 30000000,
+		Stats: parser.Stats{
+			TotalContentionTime: 30000000,
+			TotalWaits:          12,
+		},
+		Functions: []parser.Function{
+			{
+				Name:    "sync.(*Mutex).Lock",
+				File:    "sync/mutex.go",
+				Line:    46,
+				Flat:    30000000,
+				Cum:     30000000,
+				FlatPct: 100.0,
+				CumPct:  100.0,
+			},
+		},
+		Trace: &parser.TraceSummary{
+			Duration:        2 * time.Second,
+			GOMAXPROCS:      8,
+			Goroutines:      40,
+			GCCycles:        3,
+			GCPauses:        6,
+			GCPauseTotal:    900 * time.Microsecond,
+			GCPauseMax:      300 * time.Microsecond,
+			ProcUtilization: 42.5,
+		},
+	}
+
+	markdown, err := NewGenerator(profile).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"# sync Profile Analysis",
+		"- **Total Sync Wait:** 30.00ms",
+		"- **Events:** 12",
+		"- **GOMAXPROCS:** 8",
+		"- **Proc Utilization:** 42.5%",
+		"- **GC Cycles:** 3",
+		"- **Stop-the-World Pauses:** 6 (total 900.00µs, max 300.00µs)",
+		"| Sync Wait |",
+		"goroutines spend the most time waiting",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+}
+
+// TestGenerateGenericProfile tests reports of custom profiles, whose metric
+// is named and formatted after its sample type
+func TestGenerateGenericProfile(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeGeneric,
+		TotalSamples: 3_000_000_000,
+		SampleTypes:  []parser.SampleType{{Type: "wall", Unit: "seconds"}, {Type: "requests", Unit: "count"}},
+		Metric:       parser.SampleType{Type: "wall", Unit: "nanoseconds"},
+		Functions: []parser.Function{
+			{
+				Name:    "main.handle",
+				File:    "main.go",
+				Line:    12,
+				Flat:    3_000_000_000,
+				Cum:     3_000_000_000,
+				FlatPct: 100.0,
+				CumPct:  100.0,
+			},
+		},
+	}
+
+	markdown, err := NewGenerator(profile).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"# generic Profile Analysis",
+		"- **Total wall:** 3.00s",
+		"- **Sample Types:** `wall` (seconds), `requests` (count)",
+		"| Rank | Function | File | wall |",
+		"| 1 | `main.handle` | main.go:12 | 3.00s |",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+}
+
+// TestGenerateSampleIndex tests that the selected sample type names the
+// metric column
+func TestGenerateSampleIndex(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeHeap,
+		TotalSamples: 2048,
+		Metric:       parser.SampleType{Type: "inuse_space", Unit: "bytes"},
+		Functions: []parser.Function{
+			{Name: "main.cache", File: "main.go", Line: 7, Flat: 2048, Cum: 2048, FlatPct: 100, CumPct: 100},
+		},
+	}
+
+	markdown, err := NewGenerator(profile, WithSampleIndex("inuse_space")).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !contains(markdown, "| In-Use Bytes |") || !contains(markdown, "| 2.0 KiB |") {
+		t.Errorf("expected an In-Use Bytes column in bytes, got:\n%s", markdown)
+	}
+
+	if _, err := NewGenerator(profile, WithSampleIndex("alloc_space")).Generate(); err == nil {
+		t.Error("expected an error for a sample type the profile cannot select")
+	}
+}
+
+// TestGenerateGoroutineCreationSites tests grouping goroutines by creation site
+func TestGenerateGoroutineCreationSites(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeGoroutine,
+		TotalSamples: 10,
+		Stats:        parser.Stats{TotalGoroutines: 10},
+		Samples: []parser.Sample{
+			{Stack: []string{"main.(*Pool).worker", "runtime.chanrecv1", "runtime.gopark"}, Value: 6, CreatedBy: "main.(*Pool).Start"},
+			{Stack: []string{"main.(*Pool).drain", "runtime.gopark"}, Value: 1, CreatedBy: "main.(*Pool).Start"},
+			{Stack: []string{"runtime.goexit", "main.serve", "runtime.gopark"}, Value: 3},
+		},
+	}
+
+	markdown, err := NewGenerator(profile).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"## Goroutines by Creation Site",
+		"| 1 | `main.(*Pool).Start` | 7 | 70.00% | 2 | `main.(*Pool).worker` (6), `main.(*Pool).drain` (1) |",
+		"| 2 | `main.serve` _(entry function)_ | 3 | 30.00% | 1 | `main.serve` (3) |",
+	}
+
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+}
+
 // TestTopNLimit tests that the TopN limit is respected
 func TestTopNLimit(t *testing.T) {
 	// Create profile with more functions than topN
@@ -285,21 +442,903 @@ func TestTopNLimit(t *testing.T) {
 	}
 
 	profile := &parser.Profile{
-		Type:        parser.TypeCPU,
-		TotalSamples: 1000,
-		Functions:   functions,
+		Type:        parser.TypeCPU,
+		TotalSamples: 1000,
+		Functions:   functions,
+	}
+
+	gen := NewGenerator(profile, WithTopN(10))
+	markdown, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	// Count how many function rows are in the table
+	// This is a simple check - the markdown should have limited functions
+	if !contains(markdown, "testFunction") {
+		t.Error("markdown should contain functions")
+	}
+}
+
+// TestGenerateCallPathLimits tests call path limits, thresholds and weights
+func TestGenerateCallPathLimits(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 200000000,
+		Functions: []parser.Function{
+			{
+				Name: "main.hot",
+				File: "main.go",
+				Line: 10,
+				Flat: 100000000,
+				Cum:  100000000,
+				CallPaths: []parser.CallPath{
+					{Stack: []string{"main.main", "main.a", "main.hot"}, Weight: 60000000},
+					{Stack: []string{"main.main", "main.b", "main.hot"}, Weight: 30000000},
+					{Stack: []string{"main.main", "main.c", "main.hot"}, Weight: 8000000},
+					{Stack: []string{"main.main", "main.d", "main.hot"}, Weight: 2000000},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name: "all paths",
+			expected: []string{
+				"**Call Path #1** (60.00ms, 30.00%)",
+				"**Call Path #4** (2.00ms, 1.00%)",
+			},
+			unexpected: []string{"other paths"},
+		},
+		{
+			name:       "max paths",
+			opts:       []Option{WithMaxPaths(2)},
+			expected:   []string{"**Call Path #2** (30.00ms, 15.00%)", "_2 other paths (10.00ms, 5.00%)_"},
+			unexpected: []string{"**Call Path #3**"},
+		},
+		{
+			name:       "threshold",
+			opts:       []Option{WithPathThreshold(5)},
+			expected:   []string{"**Call Path #3** (8.00ms, 4.00%)", "_1 other path (2.00ms, 1.00%)_"},
+			unexpected: []string{"**Call Path #4**"},
+		},
+		{
+			name:     "threshold and max paths",
+			opts:     []Option{WithPathThreshold(5), WithMaxPaths(1)},
+			expected: []string{"**Call Path #1**", "_3 other paths (40.00ms, 20.00%)_"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown, err := NewGenerator(profile, tt.opts...).Generate()
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !contains(markdown, expected) {
+					t.Errorf("markdown missing expected string: %s", expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if contains(markdown, unexpected) {
+					t.Errorf("markdown should not contain: %s", unexpected)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerateMaxTokens tests that reports are trimmed to fit a token budget
+func TestGenerateMaxTokens(t *testing.T) {
+	functions := make([]parser.Function, 30)
+	for i := range functions {
+		var paths []parser.CallPath
+		for p := 0; p < 10; p++ {
+			stack := make([]string, 40)
+			for f := range stack {
+				stack[f] = fmt.Sprintf("github.com/example/service/internal/pkg%d.frame%d", p, f)
+			}
+			paths = append(paths, parser.CallPath{Stack: stack, Weight: int64(100 - p)})
+		}
+		functions[i] = parser.Function{
+			Name:      fmt.Sprintf("main.function%d", i),
+			File:      "main.go",
+			Line:      i,
+			Flat:      int64(1000 - i),
+			Cum:       int64(1000 - i),
+			CallPaths: paths,
+		}
+	}
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 30000,
+		Functions:    functions,
+	}
+
+	full, err := NewGenerator(profile, WithAIPrompt(false)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if contains(full, "trimmed to fit") {
+		t.Error("untrimmed report should not contain a budget note")
+	}
+
+	for _, budget := range []int{20000, 5000, 1000} {
+		markdown, err := NewGenerator(profile, WithAIPrompt(false), WithMaxTokens(budget)).Generate()
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if tokens := EstimateTokens(markdown); tokens > budget {
+			t.Errorf("budget %d: report has %d tokens", budget, tokens)
+		}
+		if !contains(markdown, "trimmed to fit a budget of about") {
+			t.Errorf("budget %d: report missing budget note", budget)
+		}
+		if !contains(markdown, "main.function0") {
+			t.Errorf("budget %d: report dropped the top function", budget)
+		}
+	}
+
+	// Optional sections are dropped before any top function rows
+	for _, fn := range functions {
+		profile.Samples = append(profile.Samples, parser.Sample{Stack: append(fn.CallPaths[0].Stack, fn.Name), Value: fn.Flat})
+	}
+	options := []Option{WithAIPrompt(false), WithFlameGraph(true), WithCallTree(true), WithPeek(true), WithMermaid(true)}
+	budget := 6000
+	markdown, err := NewGenerator(profile, append(options, WithMaxTokens(budget))...).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if tokens := EstimateTokens(markdown); tokens > budget {
+		t.Errorf("report with optional sections has %d tokens, want at most %d", tokens, budget)
+	}
+	if !contains(markdown, "720 outer stack frames, the flame graph, the call graph and the call trees") {
+		t.Errorf("budget note does not list the dropped sections:\n%s", markdown)
+	}
+	for _, unexpected := range []string{"## Flame Graph", "```mermaid", "lower-ranked function"} {
+		if contains(markdown, unexpected) {
+			t.Errorf("trimmed report contains %q", unexpected)
+		}
+	}
+}
+
+// TestGenerateSplit tests splitting a report into linked parts
+func TestGenerateSplit(t *testing.T) {
+	// Sources are found under the source directory, not at the recorded path
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		var src strings.Builder
+		for n := 1; n <= 50; n++ {
+			fmt.Fprintf(&src, "x%d\n", n)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", i)), []byte(src.String()), 0644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+	}
+
+	var functions []parser.Function
+	for i, name := range []string{"main.handle", "encoding/json.Unmarshal", "main.parse", "runtime.mallocgc"} {
+		functions = append(functions, parser.Function{
+			Name:  name,
+			File:  fmt.Sprintf("/build/app/file%d.go", i%2),
+			Line:  10 * (i + 1),
+			Flat:  int64(100 - i),
+			Cum:   int64(100 - i),
+			Lines: []parser.LineValue{{Line: 10*(i+1) + 2, Flat: int64(100 - i), Cum: int64(100 - i)}},
+			CallPaths: []parser.CallPath{
+				{Stack: []string{"runtime.main", "main.main", name}, Weight: int64(100 - i)},
+			},
+		})
+	}
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 400,
+		Functions:    functions,
+	}
+
+	const maxBytes = 500
+	parts, err := NewGenerator(profile, WithSourceDir(dir)).GenerateSplit(maxBytes)
+	if err != nil {
+		t.Fatalf("GenerateSplit failed: %v", err)
+	}
+
+	if parts[0].Name != "00-index.md" {
+		t.Fatalf("first part = %s, want 00-index.md", parts[0].Name)
+	}
+	index := parts[0].Body
+	if !contains(index, "## AI Analysis Request") {
+		t.Error("index missing AI prompt")
+	}
+
+	var all string
+	for _, part := range parts[1:] {
+		if !contains(index, "]("+part.Name+")") {
+			t.Errorf("index missing link to %s", part.Name)
+		}
+		if len(part.Body) > maxBytes {
+			t.Errorf("part %s is %d bytes, want at most %d", part.Name, len(part.Body), maxBytes)
+		}
+		all += part.Body
+	}
+
+	expectedStrings := []string{
+		"01-summary.md",
+		"-paths-main.md",
+		"-paths-encoding-json.md",
+		"# Call Paths: encoding/json",
+		"-sources-1.md",
+		"# Source Listings",
+		"# Source Listings\n\n### `encoding/json.Unmarshal`\n\n" + filepath.Join(dir, "file1.go") + ":22 (flat 99ns, cumulative 99ns)\n\n```go\n     17  x17\n",
+		"→    22  x22  // flat 99ns, cum 99ns\n",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(index+all, expected) {
+			t.Errorf("split report missing expected string: %s", expected)
+		}
+	}
+	for _, fn := range functions {
+		if !contains(all, "| `"+fn.Name+"` |") {
+			t.Errorf("split report missing row for %s", fn.Name)
+		}
+	}
+
+	// Without the source directory, the listing notes the missing file
+	parts, err = NewGenerator(profile).GenerateSplit(maxBytes)
+	if err != nil {
+		t.Fatalf("GenerateSplit failed: %v", err)
+	}
+	all = ""
+	for _, part := range parts {
+		all += part.Body
+	}
+	if !contains(all, "_/build/app/file0.go:10: source file not found;") {
+		t.Errorf("missing source not noted:\n%s", all)
+	}
+}
+
+// TestPackageName tests extracting the package of a Go symbol
+func TestPackageName(t *testing.T) {
+	tests := []struct {
+		symbol   string
+		expected string
+	}{
+		{"main.main", "main"},
+		{"runtime.gopark", "runtime"},
+		{"encoding/json.(*decodeState).object", "encoding/json"},
+		{"nodots", "nodots"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			if result := packageName(tt.symbol); result != tt.expected {
+				t.Errorf("packageName(%s) = %s, want %s", tt.symbol, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGeneratePeek tests the callers and callees tables
+func TestGeneratePeek(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 100000000,
+		Functions: []parser.Function{
+			{
+				Name:    "main.work",
+				Flat:    40000000,
+				Cum:     80000000,
+				Callers: []parser.Edge{{Name: "main.main", Weight: 60000000}, {Name: "main.init", Weight: 20000000}},
+				Callees: []parser.Edge{{Name: "main.hash", Weight: 40000000}},
+			},
+		},
+	}
+
+	markdown, err := NewGenerator(profile, WithPeek(true)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"## Callers and Callees",
+		"### `main.work`",
+		"Flat 40.00ms, cumulative 80.00ms",
+		"| `main.main` | 60.00ms | 75.00% |",
+		"| `main.init` | 20.00ms | 25.00% |",
+		"| `main.hash` | 40.00ms | 50.00% |",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+
+	markdown, err = NewGenerator(profile).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if contains(markdown, "## Callers and Callees") {
+		t.Error("markdown should not contain callers and callees without WithPeek")
+	}
+}
+
+// TestGenerateMermaid tests the Mermaid call graph
+func TestGenerateMermaid(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 1000000000,
+		Functions: []parser.Function{
+			{
+				Name:    "github.com/acme/app/db.(*Conn).Query",
+				Flat:    600000000,
+				Cum:     600000000,
+				Callers: []parser.Edge{{Name: "main.main", Weight: 600000000}},
+			},
+			{
+				Name:    "main.main",
+				Flat:    100000000,
+				Cum:     1000000000,
+				Callees: []parser.Edge{{Name: "github.com/acme/app/db.(*Conn).Query", Weight: 600000000}, {Name: "main.log", Weight: 300000000}},
+			},
+			{Name: "main.log", Flat: 300000000, Cum: 300000000},
+			{Name: "main.tiny", Flat: 0, Cum: 1000},
+		},
+	}
+
+	markdown, err := NewGenerator(profile, WithMermaid(true), WithEdgeFraction(0.4)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"```mermaid\nflowchart TD",
+		`n1["main.main<br/>100.00% cum"]:::hot`,
+		`n2["db.(*Conn).Query<br/>60.00% cum"]:::hot`,
+		`n3["main.log<br/>30.00% cum"]:::hot`,
+		`n1 ==>|"600.00ms"| n2`,
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s\n%s", expected, markdown)
+		}
+	}
+	for _, unexpected := range []string{"main.tiny<br/>", "n1 ==>|\"300.00ms\"| n3"} {
+		if contains(markdown, unexpected) {
+			t.Errorf("markdown should not contain: %s", unexpected)
+		}
+	}
+}
+
+// TestGenerateDOT tests Graphviz call graph output
+func TestGenerateDOT(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 1000000000,
+		Functions: []parser.Function{
+			{
+				Name:    "main.main",
+				Flat:    400000000,
+				Cum:     1000000000,
+				Callees: []parser.Edge{{Name: "github.com/acme/app/db.(*Conn).Query", Weight: 600000000}},
+			},
+			{Name: "github.com/acme/app/db.(*Conn).Query", Flat: 600000000, Cum: 600000000},
+		},
+	}
+
+	dot, err := NewGenerator(profile).GenerateDOT()
+	if err != nil {
+		t.Fatalf("GenerateDOT failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		`digraph "cpu profile" {`,
+		`n1 [label="main.main\n400.00ms (40.00%)\nof 1.00s (100.00%)"`,
+		`n2 [label="db.(*Conn).Query\n600.00ms (60.00%)\nof 600.00ms (60.00%)" tooltip="github.com/acme/app/db.(*Conn).Query"`,
+		`n1 -> n2 [label=" 600.00ms"`,
+	}
+	for _, expected := range expectedStrings {
+		if !contains(dot, expected) {
+			t.Errorf("dot missing expected string: %s\n%s", expected, dot)
+		}
+	}
+}
+
+// TestDiffGenerateDOT tests diff coloring in Graphviz output
+func TestDiffGenerateDOT(t *testing.T) {
+	base := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 1000000000,
+		Functions: []parser.Function{
+			{Name: "main.grew", Flat: 100000000, Cum: 100000000},
+			{Name: "main.shrank", Flat: 900000000, Cum: 900000000},
+		},
+	}
+	newProfile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 1000000000,
+		Functions: []parser.Function{
+			{Name: "main.grew", Flat: 800000000, Cum: 800000000},
+			{Name: "main.shrank", Flat: 200000000, Cum: 200000000},
+		},
+	}
+
+	dot, err := NewDiffGenerator(base, newProfile).GenerateDOT()
+	if err != nil {
+		t.Fatalf("GenerateDOT failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		`digraph "cpu profile diff" {`,
+		"Red: grew, green: shrank",
+		`\n+700.00ms"`,
+		`\n-700.00ms"`,
+	}
+	for _, expected := range expectedStrings {
+		if !contains(dot, expected) {
+			t.Errorf("dot missing expected string: %s\n%s", expected, dot)
+		}
+	}
+}
+
+// TestDotColors tests call graph node shading
+func TestDotColors(t *testing.T) {
+	tests := []struct {
+		name   string
+		score  float64
+		prefix string
+	}{
+		{"growth is red", 0.5, "#ff"},
+		{"unchanged is grey", 0, "#f8f8f8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if fill, _ := dotColors(tt.score); fill[:len(tt.prefix)] != tt.prefix {
+				t.Errorf("dotColors(%v) fill = %s, want prefix %s", tt.score, fill, tt.prefix)
+			}
+		})
+	}
+	if fill, _ := dotColors(-0.5); fill[3:5] != "ff" {
+		t.Errorf("dotColors(-0.5) fill = %s, want green", fill)
+	}
+}
+
+// TestGenerateFolded tests collapsed-stack output
+func TestGenerateFolded(t *testing.T) {
+	// Imported stacks count samples; the primary metric scales them to CPU time
+	profile, err := parser.ParseData([]byte("main;work 3\nmain;idle 1\nmain;work 2\n"), parser.TypeCPU)
+	if err != nil {
+		t.Fatalf("ParseData failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		sampleIndex string
+		expected    string
+		wantErr     bool
+	}{
+		{"primary metric", "", "main;idle 10000000\nmain;work 50000000\n", false},
+		{"sample index", "samples", "main;idle 1\nmain;work 5\n", false},
+		{"unknown sample index", "alloc_space", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded, err := NewGenerator(profile, WithSampleIndex(tt.sampleIndex)).GenerateFolded()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateFolded error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if folded != tt.expected {
+				t.Errorf("GenerateFolded = %q, want %q", folded, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGenerateHTML tests the self-contained HTML report
+func TestGenerateHTML(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeHeap,
+		TotalSamples: 3072,
+		Stats:        parser.Stats{AllocBytes: 3072, AllocObjects: 3},
+		Functions: []parser.Function{
+			{Name: "main.alloc<T>", File: "main.go", Line: 7, Flat: 3072, Cum: 3072, FlatPct: 100, CumPct: 100},
+		},
+		Samples: []parser.Sample{
+			{Stack: []string{"main.main", "main.alloc<T>"}, Value: 2048},
+			{Stack: []string{"main.main", "main.alloc<T>"}, Value: 1024},
+		},
+	}
+
+	html, err := NewGenerator(profile).GenerateHTML()
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"<title>heap Profile Analysis</title>",
+		"<tr><th>Allocated Bytes</th><td class=\"num\">3.0 KiB</td></tr>",
+		"<code>main.alloc&lt;T&gt;</code>",
+		`var root = {"n":"root","v":3072,"c":[{"n":"main.main","v":3072,"c":[{"n":"main.alloc\u003cT\u003e","v":3072}]}]};`,
+		`var unit = "bytes";`,
+	}
+	for _, expected := range expectedStrings {
+		if !contains(html, expected) {
+			t.Errorf("html missing expected string: %s", expected)
+		}
+	}
+	for _, unexpected := range []string{"<script src=", "<link "} {
+		if contains(html, unexpected) {
+			t.Errorf("html should not load external assets: %s", unexpected)
+		}
+	}
+}
+
+// TestGenerateFlameGraph tests the text flame graph section
+func TestGenerateFlameGraph(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeGoroutine,
+		TotalSamples: 4,
+		Samples: []parser.Sample{
+			{Stack: []string{"main.main", "main.a"}, Value: 3},
+			{Stack: []string{"main.main", "github.com/acme/app/b.Run"}, Value: 1},
+		},
+	}
+
+	markdown, err := NewGenerator(profile, WithFlameGraph(true)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"## Flame Graph",
+		"```text\n[" + strings.Repeat("-", 42) + " root 100.0% " + strings.Repeat("-", 43) + "]\n",
+		"[" + strings.Repeat("-", 29) + " main.a 75.0% " + strings.Repeat("-", 30) + "][----- b.Run 25.0% -----]\n```",
+		"_Frames shared by every stack, below root: `main.main`._",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s\n%s", expected, markdown)
+		}
+	}
+
+	// Deep stacks keep their application frames and name the cut frames
+	var stack []string
+	for i := 0; i < 3; i++ {
+		stack = append(stack, fmt.Sprintf("runtime.f%d", i))
+	}
+	for i := 0; i < 20; i++ {
+		stack = append(stack, fmt.Sprintf("main.f%d", i))
+	}
+	profile.Samples = []parser.Sample{
+		{Stack: stack, Value: 3},
+		{Stack: []string{"runtime.goexit", "main.other"}, Value: 1},
+	}
+	markdown, err = NewGenerator(profile, WithFlameGraph(true)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	expectedStrings = []string{
+		" main.f0 75.0% ",
+		" main.f15 75.0% ",
+		"_Runtime and standard library frames at the root of each stack are elided._",
+		"_Stacks deeper than 16 levels are cut off at: `main.f16` (75.0%, hottest leaf `main.f19`)._",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s\n%s", expected, markdown)
+		}
+	}
+	if flame := markdown[strings.Index(markdown, "## Flame Graph"):]; contains(flame, "runtime.f0") {
+		t.Errorf("runtime root frames not elided:\n%s", markdown)
+	}
+}
+
+// TestFlameBox tests text flame graph frame drawing
+func TestFlameBox(t *testing.T) {
+	tests := []struct {
+		width    int
+		expected string
+	}{
+		{20, "[ main.work 50.0% -]"},
+		{14, "[ main.work -]"},
+		{8, "[ main…]"},
+		{3, "[-]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.width), func(t *testing.T) {
+			box := string(flameBox("main.work", 50, tt.width))
+			if box != tt.expected {
+				t.Errorf("flameBox(%d) = %q, want %q", tt.width, box, tt.expected)
+			}
+			if n := len([]rune(box)); n != tt.width {
+				t.Errorf("flameBox(%d) is %d characters wide", tt.width, n)
+			}
+		})
+	}
+}
+
+// TestGenerateSpeedscope tests speedscope JSON output
+func TestGenerateSpeedscope(t *testing.T) {
+	heap := &parser.Profile{
+		Type:        parser.TypeHeap,
+		SampleTypes: []parser.SampleType{{Type: "alloc_objects", Unit: "count"}, {Type: "inuse_space", Unit: "bytes"}},
+		Functions:   []parser.Function{{Name: "main.alloc", File: "main.go", Line: 7}},
+		Samples: []parser.Sample{
+			{Stack: []string{"main.main", "main.alloc"}, Value: 4096, Values: []int64{4, 0}},
+			{Stack: []string{"main.main"}, Value: 1024, Values: []int64{1, 1024}},
+		},
+	}
+	cpu := &parser.Profile{
+		Type:    parser.TypeCPU,
+		Samples: []parser.Sample{{Stack: []string{"main.main", "main.spin"}, Value: 20000000}},
+	}
+	imported, err := parser.ParseData([]byte("main;work 3\nmain;idle 1\n"), parser.TypeCPU)
+	if err != nil {
+		t.Fatalf("ParseData failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		profile  *parser.Profile
+		opts     []Option
+		expected []string
+	}{
+		{
+			name:    "heap profiles per sample type",
+			profile: heap,
+			expected: []string{
+				`"$schema":"https://www.speedscope.app/file-format-schema.json"`,
+				`"frames":[{"name":"main.main"},{"name":"main.alloc","file":"main.go","line":7}]`,
+				`{"type":"sampled","name":"alloc_objects","unit":"none","startValue":0,"endValue":5,"samples":[[0,1],[0]],"weights":[4,1]}`,
+				`{"type":"sampled","name":"inuse_space","unit":"bytes","startValue":0,"endValue":1024,"samples":[[0]],"weights":[1024]}`,
+			},
+		},
+		{
+			name:     "sample index",
+			profile:  imported,
+			opts:     []Option{WithSampleIndex("samples")},
+			expected: []string{`"profiles":[{"type":"sampled","name":"samples","unit":"none","startValue":0,"endValue":4,`},
+		},
+		{
+			name:     "primary metric of imported stacks",
+			profile:  imported,
+			expected: []string{`"profiles":[{"type":"sampled","name":"samples","unit":"nanoseconds","startValue":0,"endValue":40000000,`},
+		},
+		{
+			name:     "primary metric",
+			profile:  cpu,
+			expected: []string{`"profiles":[{"type":"sampled","name":"cpu","unit":"nanoseconds","startValue":0,"endValue":20000000,"samples":[[0,1]],"weights":[20000000]}]`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := NewGenerator(tt.profile, tt.opts...).GenerateSpeedscope()
+			if err != nil {
+				t.Fatalf("GenerateSpeedscope failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !contains(out, expected) {
+					t.Errorf("output missing expected string: %s\n%s", expected, out)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerateCallTree tests the top-down and caller tree sections
+func TestGenerateCallTree(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeGoroutine,
+		TotalSamples: 10,
+		Functions: []parser.Function{
+			{Name: "runtime.gopark", Flat: 10, Cum: 10},
+			{Name: "main.worker", Cum: 9},
+		},
+		Samples: []parser.Sample{
+			{Stack: []string{"main.main", "main.worker", "runtime.gopark"}, Value: 6},
+			{Stack: []string{"main.main", "main.serve", "main.worker", "runtime.gopark"}, Value: 3},
+			{Stack: []string{"main.main", "main.idle", "runtime.gopark"}, Value: 1},
+		},
 	}
 
-	gen := NewGenerator(profile, WithTopN(10))
-	markdown, err := gen.Generate()
+	tests := []struct {
+		name       string
+		opts       []Option
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:       "disabled",
+			unexpected: []string{"## Call Tree", "## Caller Trees"},
+		},
+		{
+			name: "enabled",
+			opts: []Option{WithCallTree(true)},
+			expected: []string{
+				"## Call Tree",
+				"100.00%  main.main (10)",
+				" 60.00%    main.worker (6)",
+				" 10.00%    main.idle (1)",
+				" 30.00%      main.worker (3)",
+				"### Callers of `main.worker`",
+				" 90.00%  main.worker (9)",
+				" 30.00%    main.serve (3)",
+			},
+		},
+		{
+			name:       "node fraction",
+			opts:       []Option{WithCallTree(true), WithNodeFraction(0.2)},
+			expected:   []string{" 60.00%    main.worker (6)", " 30.00%      main.worker (3)"},
+			unexpected: []string{"    main.idle (1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown, err := NewGenerator(profile, append(tt.opts, WithAIPrompt(false))...).Generate()
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !contains(markdown, expected) {
+					t.Errorf("markdown missing expected string: %s\n%s", expected, markdown)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if contains(markdown, unexpected) {
+					t.Errorf("markdown should not contain: %s\n%s", unexpected, markdown)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerateStackCompaction tests compacted call path rendering
+func TestGenerateStackCompaction(t *testing.T) {
+	root := []string{"runtime.goexit", "net/http.(*conn).serve", "github.com/acme/app/server.(*Server).ServeHTTP"}
+	profile := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 100000000,
+		Functions: []parser.Function{
+			{
+				Name: "github.com/acme/app/tree.walk",
+				Flat: 100000000,
+				Cum:  100000000,
+				CallPaths: []parser.CallPath{
+					{Stack: append(append([]string{}, root...), "github.com/acme/app/tree.Build", "github.com/acme/app/tree.walk", "github.com/acme/app/tree.walk", "github.com/acme/app/tree.walk"), Weight: 60000000},
+					{Stack: append(append([]string{}, root...), "github.com/acme/app/tree.Find", "github.com/acme/app/tree.walk"), Weight: 40000000},
+				},
+			},
+		},
+	}
+
+	markdown, err := NewGenerator(profile, WithAIPrompt(false), WithStackCompaction(StackCompaction{
+		ElideRuntime:      true,
+		CollapseRecursion: true,
+		TrimCommonRoot:    true,
+		ShortenPaths:      true,
+	})).Generate()
 	if err != nil {
 		t.Fatalf("Generate failed: %v", err)
 	}
 
-	// Count how many function rows are in the table
-	// This is a simple check - the markdown should have limited functions
-	if !contains(markdown, "testFunction") {
-		t.Error("markdown should contain functions")
+	expectedStrings := []string{
+		"… 2 runtime/stdlib frames elided",
+		"_All paths start with 1 common frames, ending at_ `server.(*Server).ServeHTTP`",
+		"  → tree.Build\n    tree.walk ×3",
+		"  → tree.Find\n    tree.walk",
+	}
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s\n%s", expected, markdown)
+		}
+	}
+	if contains(markdown, "    net/http") || contains(markdown, "github.com/acme/app/tree.Build") {
+		t.Error("markdown should not contain elided or unshortened frames")
+	}
+}
+
+// TestParseStackCompaction tests compaction mode parsing
+func TestParseStackCompaction(t *testing.T) {
+	tests := []struct {
+		modes    []string
+		expected StackCompaction
+		wantErr  bool
+	}{
+		{nil, StackCompaction{}, false},
+		{[]string{"runtime", "paths"}, StackCompaction{ElideRuntime: true, ShortenPaths: true}, false},
+		{[]string{"all"}, StackCompaction{ElideRuntime: true, CollapseRecursion: true, TrimCommonRoot: true, ShortenPaths: true}, false},
+		{[]string{"bogus"}, StackCompaction{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.modes), func(t *testing.T) {
+			result, err := ParseStackCompaction(tt.modes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStackCompaction(%v) error = %v, wantErr %v", tt.modes, err, tt.wantErr)
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("ParseStackCompaction(%v) = %+v, want %+v", tt.modes, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestIsStdlibFrame tests standard library frame detection
+func TestIsStdlibFrame(t *testing.T) {
+	tests := []struct {
+		frame    string
+		expected bool
+	}{
+		{"runtime.goexit", true},
+		{"net/http.(*conn).serve", true},
+		{"main.main", false},
+		{"github.com/acme/app.Run", false},
+		{"golang.org/x/sync/errgroup.(*Group).Go.func1", false},
+		{"myapp/internal/store.(*DB).Get", false},
+		{"internal/poll.(*FD).Read", true},
+		{"slices.SortFunc[go.shape.[]myapp/internal/store.Row]", true},
+		{"nodots", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.frame, func(t *testing.T) {
+			if result := isStdlibFrame(tt.frame); result != tt.expected {
+				t.Errorf("isStdlibFrame(%s) = %v, want %v", tt.frame, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEstimateTokens tests token estimation
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"→→→→", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if result := EstimateTokens(tt.s); result != tt.expected {
+				t.Errorf("EstimateTokens(%q) = %d, want %d", tt.s, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestOmissionsString tests the omission summary
+func TestOmissionsString(t *testing.T) {
+	tests := []struct {
+		omitted  Omissions
+		expected string
+	}{
+		{Omissions{}, "nothing"},
+		{Omissions{CallPaths: 3}, "3 call paths"},
+		{Omissions{Functions: 2, Frames: 9}, "2 lower-ranked functions and 9 outer stack frames"},
+		{Omissions{Functions: 1, CallPaths: 2, Frames: 3}, "1 lower-ranked function, 2 call paths and 3 outer stack frames"},
+		{Omissions{Functions: 1, CallPaths: 1, Frames: 1}, "1 lower-ranked function, 1 call path and 1 outer stack frame"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := tt.omitted.String(); result != tt.expected {
+				t.Errorf("String() = %s, want %s", result, tt.expected)
+			}
+		})
 	}
 }
 
@@ -379,6 +1418,312 @@ func TestFormatNumber(t *testing.T) {
 	}
 }
 
+// TestDiffGenerate tests diff markdown generation with type-aware units
+func TestDiffGenerate(t *testing.T) {
+	base := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 300000000,
+		Functions: []parser.Function{
+			{Name: "main.hot", File: "main.go", Line: 10, Flat: 100000000, Cum: 200000000},
+			{Name: "main.gone", File: "gone.go", Line: 5, Flat: 50000000, Cum: 50000000},
+		},
+	}
+	newProf := &parser.Profile{
+		Type:         parser.TypeCPU,
+		TotalSamples: 400000000,
+		Functions: []parser.Function{
+			{Name: "main.hot", File: "main.go", Line: 10, Flat: 150000000, Cum: 250000000},
+			{Name: "main.added", File: "added.go", Line: 7, Flat: 20000000, Cum: 20000000},
+		},
+	}
+
+	markdown, err := NewDiffGenerator(base, newProf).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"| Base Flat | Base Cum | New Flat | New Cum |",
+		"| `main.hot` | main.go:10 | 100.00ms | 200.00ms | 150.00ms | 250.00ms | +50.00ms | +50.0% | +50.00ms | +25.0% |",
+		"| `main.gone` | gone.go:5 | 50.00ms | 50.00ms | - | - | -50.00ms |",
+		"| `main.added` | added.go:7 | - | - | 20.00ms | 20.00ms | +20.00ms |",
+		"| Total CPU Time | 300.00ms | 400.00ms | +100.00ms |",
+	}
+
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+}
+
+// TestFormatValue tests type-aware value formatting
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		profileType parser.ProfileType
+		value       int64
+		expected    string
+		delta       string
+	}{
+		{parser.TypeCPU, 1500000, "1.50ms", "+1.50ms"},
+		{parser.TypeMutex, -2000, "-2.00µs", "-2.00µs"},
+		{parser.TypeHeap, 2048, "2.0 KiB", "+2.0 KiB"},
+		{parser.TypeHeap, -1536, "-1536 B", "-1.5 KiB"},
+		{parser.TypeGoroutine, 1500, "1.5K", "+1.5K"},
+		{parser.TypeGoroutine, 0, "0", "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.profileType)+"/"+tt.expected, func(t *testing.T) {
+			if result := FormatValue(tt.profileType, tt.value); result != tt.expected {
+				t.Errorf("FormatValue(%s, %d) = %s, want %s", tt.profileType, tt.value, result, tt.expected)
+			}
+			if result := FormatValueDelta(tt.profileType, tt.value); result != tt.delta {
+				t.Errorf("FormatValueDelta(%s, %d) = %s, want %s", tt.profileType, tt.value, result, tt.delta)
+			}
+		})
+	}
+}
+
+// TestFormatLocation tests source location formatting
+func TestFormatLocation(t *testing.T) {
+	tests := []struct {
+		file     string
+		line     int
+		expected string
+	}{
+		{"main.go", 10, "main.go:10"},
+		{"main.go", 0, "main.go"},
+		{"", 10, "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := FormatLocation(tt.file, tt.line); result != tt.expected {
+				t.Errorf("FormatLocation(%q, %d) = %s, want %s", tt.file, tt.line, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTrendGenerate tests trend markdown generation and snapshot ordering
+func TestTrendGenerate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := func(offset time.Duration, leak, noise int64) *parser.Profile {
+		return &parser.Profile{
+			Type:         parser.TypeHeap,
+			Time:         start.Add(offset),
+			TotalSamples: leak + noise,
+			Stats:        parser.Stats{AllocBytes: leak + noise, InUseBytes: leak},
+			Functions: []parser.Function{
+				{Name: "main.leak", File: "leak.go", Line: 3, Flat: leak},
+				{Name: "main.noise", File: "noise.go", Line: 8, Flat: noise},
+			},
+		}
+	}
+
+	// Labels are deliberately out of time order
+	points := []TrendPoint{
+		{Label: "c.prof", Profile: snapshot(0, 1024, 4096)},
+		{Label: "a.prof", Profile: snapshot(10*time.Minute, 2048, 1024)},
+		{Label: "b.prof", Profile: snapshot(20*time.Minute, 4096, 2048)},
+	}
+
+	markdown, err := NewTrendGenerator(points).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"# heap Profile Trend",
+		"| 1 | `c.prof` | 2024-01-01 00:00:00 | 0 |",
+		"| 3 | `b.prof` | 2024-01-01 00:20:00 | 20m0s |",
+		"| In-Use Bytes | `▁▃█` | 1.0 KiB | 4.0 KiB | 1.0 KiB | 4.0 KiB | +3.0 KiB | +300.0% | steadily growing |",
+		"| `main.noise` | noise.go:8 | `█▁▃` | 4.0 KiB | 2.0 KiB | 4.0 KiB | -2.0 KiB | -50.0% | shrinking |",
+		"## Steadily Growing Functions",
+		"| 1 | `main.leak` | leak.go:3 | `▁▃█` |",
+	}
+
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+}
+
+// TestTrendHeapLeaks tests suspected leak detection across heap snapshots
+func TestTrendHeapLeaks(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sampleTypes := []parser.SampleType{
+		{Type: "alloc_objects", Unit: "count"},
+		{Type: "alloc_space", Unit: "bytes"},
+		{Type: "inuse_objects", Unit: "count"},
+		{Type: "inuse_space", Unit: "bytes"},
+	}
+	snapshot := func(minute int, cached, transient int64) *parser.Profile {
+		return &parser.Profile{
+			Type:        parser.TypeHeap,
+			Time:        start.Add(time.Duration(minute) * time.Minute),
+			SampleTypes: sampleTypes,
+			Functions: []parser.Function{
+				{Name: "main.cachePut", File: "cache.go", Line: 12},
+			},
+			Samples: []parser.Sample{
+				{Stack: []string{"main.main", "main.cachePut"}, Values: []int64{0, 0, cached / 64, cached}},
+				{Stack: []string{"main.main", "main.handle"}, Values: []int64{0, 0, 1, transient}},
+			},
+		}
+	}
+
+	points := []TrendPoint{
+		{Label: "1.prof", Profile: snapshot(0, 64*1024, 4096)},
+		{Label: "2.prof", Profile: snapshot(10, 128*1024, 1024)},
+		{Label: "3.prof", Profile: snapshot(20, 192*1024, 8192)},
+	}
+
+	markdown, err := NewTrendGenerator(points).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"## Suspected Leaks",
+		"| Growth/min |",
+		"| 1 | `main.cachePut` | cache.go:12 | 64.0 KiB → 192.0 KiB | 1.0K → 3.1K | +6.4 KiB | +102 | `▁▄█` |",
+		"### 1. main.cachePut",
+		"  → main.cachePut\n    main.main",
+	}
+
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+	if contains(markdown, "`main.handle`") {
+		t.Error("non-monotonic allocation site should not be reported as a leak")
+	}
+}
+
+// TestDiffGoroutineLeaks tests that goroutine diffs report growth by creation site
+func TestDiffGoroutineLeaks(t *testing.T) {
+	snapshot := func(workers int64) *parser.Profile {
+		return &parser.Profile{
+			Type: parser.TypeGoroutine,
+			Samples: []parser.Sample{
+				{Stack: []string{"runtime.goexit", "main.startWorkers.func1", "runtime.gopark"}, Value: workers},
+				{Stack: []string{"runtime.goexit", "main.handle", "runtime.gopark"}, Value: 2 * workers, CreatedBy: "main.(*Server).Serve"},
+				{Stack: []string{"runtime.goexit", "main.serve", "runtime.gopark"}, Value: 4},
+			},
+		}
+	}
+
+	markdown, err := NewDiffGenerator(snapshot(2), snapshot(12)).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	expectedStrings := []string{
+		"## Growing Goroutines",
+		"| 1 | `main.(*Server).Serve` | 1 | 4 → 24 | +20.0 |",
+		"| 2 | `main.startWorkers.func1` _(entry function)_ | 1 | 2 → 12 | +10.0 |",
+		"| 2 | `runtime.gopark` | `main.startWorkers.func1` _(entry function)_ | 2 → 12 | +10.0 |",
+		"#### 1. main.(*Server).Serve → runtime.gopark",
+		"#### 2. main.startWorkers.func1 (entry function) → runtime.gopark",
+	}
+
+	for _, expected := range expectedStrings {
+		if !contains(markdown, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+	if contains(markdown, "`main.serve`") {
+		t.Error("stable goroutine stack should not be reported as growing")
+	}
+}
+
+// TestEntryFunction tests finding the function a goroutine was started with
+func TestEntryFunction(t *testing.T) {
+	tests := []struct {
+		stack    []string
+		expected string
+	}{
+		{[]string{"runtime.goexit", "main.worker", "runtime.gopark"}, "main.worker"},
+		{[]string{"runtime.main", "main.main"}, "runtime.main"},
+		{[]string{"runtime.goexit"}, "unknown"},
+		{nil, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := entryFunction(tt.stack); result != tt.expected {
+				t.Errorf("entryFunction(%v) = %s, want %s", tt.stack, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestOrderTrendPointsByLabel tests that label order is used without timestamps
+func TestOrderTrendPointsByLabel(t *testing.T) {
+	points := []TrendPoint{
+		{Label: "heap-2.prof", Profile: &parser.Profile{Time: time.Unix(100, 0)}},
+		{Label: "heap-1.prof", Profile: &parser.Profile{}},
+		{Label: "heap-3.prof", Profile: &parser.Profile{Time: time.Unix(50, 0)}},
+	}
+
+	ordered := OrderTrendPoints(points)
+	for i, want := range []string{"heap-1.prof", "heap-2.prof", "heap-3.prof"} {
+		if ordered[i].Label != want {
+			t.Errorf("ordered[%d] = %s, want %s", i, ordered[i].Label, want)
+		}
+	}
+}
+
+// TestSeriesTrend tests series direction classification
+func TestSeriesTrend(t *testing.T) {
+	tests := []struct {
+		values   []int64
+		expected string
+	}{
+		{[]int64{1}, "-"},
+		{[]int64{1, 2}, "growing"},
+		{[]int64{1, 1, 3}, "steadily growing"},
+		{[]int64{3, 2, 1}, "steadily shrinking"},
+		{[]int64{1, 5, 2}, "growing"},
+		{[]int64{5, 1, 2}, "shrinking"},
+		{[]int64{2, 5, 2}, "stable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			s := Series{Values: tt.values}
+			if result := s.Trend(); result != tt.expected {
+				t.Errorf("Trend(%v) = %s, want %s", tt.values, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSparkline tests text sparkline rendering
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values   []int64
+		expected string
+	}{
+		{nil, ""},
+		{[]int64{5, 5, 5}, "▁▁▁"},
+		{[]int64{0, 7}, "▁█"},
+		{[]int64{0, 1, 2, 3, 4, 5, 6, 7}, "▁▂▃▄▅▆▇█"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := Sparkline(tt.values); result != tt.expected {
+				t.Errorf("Sparkline(%v) = %s, want %s", tt.values, result, tt.expected)
+			}
+		})
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && findSubstr(s, substr)
@@ -392,3 +1737,58 @@ func findSubstr(s, substr string) bool {
 	}
 	return false
 }
+
+// TestGenerateJSON tests the JSON report
+func TestGenerateJSON(t *testing.T) {
+	profile := &parser.Profile{
+		Type:         parser.TypeHeap,
+		TotalSamples: 4096,
+		Metric:       parser.SampleType{Type: "alloc_space", Unit: "bytes"},
+		Stats:        parser.Stats{AllocBytes: 4096, AllocObjects: 4},
+		Functions: []parser.Function{
+			{
+				Name:      "main.alloc",
+				File:      "main.go",
+				Line:      7,
+				Flat:      4096,
+				Cum:       4096,
+				FlatPct:   100.0,
+				CumPct:    100.0,
+				CallPaths: []parser.CallPath{{Stack: []string{"main.main", "main.alloc"}, Weight: 4096}},
+				Callers:   []parser.Edge{{Name: "main.main", Weight: 4096}},
+			},
+		},
+	}
+
+	out, err := NewGenerator(profile).GenerateJSON()
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+
+	var report JSONReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Version != JSONSchemaVersion || report.Type != "heap" || report.Metric != "alloc_space" || report.Unit != "bytes" || report.Total != 4096 {
+		t.Errorf("unexpected report header: %+v", report)
+	}
+	if report.Stats["alloc_bytes"] != 4096 || report.Stats["alloc_objects"] != 4 {
+		t.Errorf("unexpected stats: %v", report.Stats)
+	}
+	if len(report.Functions) != 1 {
+		t.Fatalf("got %d functions, want 1", len(report.Functions))
+	}
+	fn := report.Functions[0]
+	if fn.Rank != 1 || fn.Name != "main.alloc" || fn.File != "main.go" || fn.Line != 7 || fn.Flat != 4096 || fn.CumPct != 100 {
+		t.Errorf("unexpected function: %+v", fn)
+	}
+	if len(fn.CallPaths) != 1 || fn.CallPaths[0].Weight != 4096 || len(fn.Callers) != 1 || fn.Callees != nil {
+		t.Errorf("unexpected call paths or edges: %+v", fn)
+	}
+
+	for _, expected := range []string{`"flat_pct": 100`, `"call_paths": [`, `"stack": [`} {
+		if !contains(out, expected) {
+			t.Errorf("JSON missing expected string: %s", expected)
+		}
+	}
+}
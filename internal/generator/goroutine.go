@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// CreationSite groups goroutines by the site that started them
+type CreationSite struct {
+	CreatedBy string
+	Inferred  bool // CreatedBy is the entry function; no "created by" was recorded
+	Count     int64
+	Pct       float64 // Percentage of all goroutines
+	Stacks    int     // Number of distinct stacks started here
+	WaitingIn []SiteCount
+}
+
+// SiteCount is a number of goroutines attributed to a function
+type SiteCount struct {
+	Name  string
+	Count int64
+}
+
+// maxWaitingIn is the number of waiting frames listed per creation site
+const maxWaitingIn = 3
+
+// groupByCreationSite groups goroutine samples by creation site, ranked by
+// goroutine count. Within each site, goroutines are also attributed to the
+// innermost non-runtime frame they are waiting in.
+func groupByCreationSite(profile *parser.Profile, topN int) []CreationSite {
+	var total int64
+	sites := make(map[string]*CreationSite)
+	waiting := make(map[string]map[string]int64)
+	var keys []string
+
+	for _, s := range profile.Samples {
+		total += s.Value
+		name, inferred := sampleCreationSite(s)
+		// A recorded site and an entry function of the same name differ
+		key := name
+		if inferred {
+			key += "\x00entry"
+		}
+		site, ok := sites[key]
+		if !ok {
+			site = &CreationSite{CreatedBy: name, Inferred: inferred}
+			sites[key] = site
+			waiting[key] = make(map[string]int64)
+			keys = append(keys, key)
+		}
+		site.Count += s.Value
+		site.Stacks++
+		waiting[key][waitSite(s.Stack)] += s.Value
+	}
+
+	result := make([]CreationSite, 0, len(keys))
+	for _, key := range keys {
+		site := sites[key]
+		if total > 0 {
+			site.Pct = float64(site.Count) / float64(total) * 100
+		}
+		for fn, count := range waiting[key] {
+			site.WaitingIn = append(site.WaitingIn, SiteCount{Name: fn, Count: count})
+		}
+		sort.Slice(site.WaitingIn, func(i, j int) bool {
+			if site.WaitingIn[i].Count != site.WaitingIn[j].Count {
+				return site.WaitingIn[i].Count > site.WaitingIn[j].Count
+			}
+			return site.WaitingIn[i].Name < site.WaitingIn[j].Name
+		})
+		if len(site.WaitingIn) > maxWaitingIn {
+			site.WaitingIn = site.WaitingIn[:maxWaitingIn]
+		}
+		result = append(result, *site)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].CreatedBy < result[j].CreatedBy
+	})
+	if len(result) > topN {
+		result = result[:topN]
+	}
+	return result
+}
+
+// sampleCreationSite returns the recorded "created by" site of a sample.
+// Binary goroutine profiles record none, so it falls back to the entry
+// function and reports the site as inferred.
+func sampleCreationSite(s parser.Sample) (site string, inferred bool) {
+	if s.CreatedBy != "" {
+		return s.CreatedBy, false
+	}
+	return entryFunction(s.Stack), true
+}
+
+// entryFunction returns the function a goroutine was started with, which is
+// the outermost frame below runtime.goexit. It is the goroutine's body, not
+// the function that called go.
+func entryFunction(stack []string) string {
+	for _, frame := range stack {
+		if frame != "runtime.goexit" {
+			return frame
+		}
+	}
+	return "unknown"
+}
+
+// waitSite returns the innermost frame outside the runtime, which is where
+// user code is blocked (the leaf is usually runtime.gopark)
+func waitSite(stack []string) string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(stack[i], "runtime.") {
+			return stack[i]
+		}
+	}
+	if len(stack) > 0 {
+		return stack[len(stack)-1]
+	}
+	return "unknown"
+}
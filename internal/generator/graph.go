@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// DefaultEdgeFraction is the default minimum weight of a call graph edge as
+// a fraction of the profile total, matching pprof's -edgefraction
+const DefaultEdgeFraction = 0.001
+
+// maxGraphNodes is the largest number of functions drawn in a call graph
+const maxGraphNodes = 40
+
+// CallGraph is the hot subgraph of a profile's call graph
+type CallGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+	Diff  bool // Nodes and edges carry deltas against a base profile
+}
+
+// GraphNode is a function in a call graph
+type GraphNode struct {
+	ID     string // Identifier safe for diagram languages, e.g. "n1"
+	Name   string
+	Label  string // Short display name
+	Flat   int64
+	Cum    int64
+	CumPct float64
+	Delta  int64  // Change in Cum from the base profile, for diff graphs
+	Heat   string // "hot", "warm" or "cold" by CumPct
+}
+
+// GraphEdge is the weight flowing from a caller to a callee
+type GraphEdge struct {
+	From   string // Caller node ID
+	To     string // Callee node ID
+	Weight int64
+	Pct    float64 // Weight as a percentage of the profile total
+	Delta  int64   // Change in Weight from the base profile, for diff graphs
+	Heavy  bool    // Carries at least heavyEdgePct percent of the total
+}
+
+// heavyEdgePct is the share of the total above which edges are drawn bold
+const heavyEdgePct = 10
+
+// buildCallGraph returns the hot call graph of the generator's profile
+func (g *Generator) buildCallGraph() CallGraph {
+	return buildCallGraph(nil, g.profile, g.nodeFraction, g.edgeFraction)
+}
+
+// graphFunction is a function's values in the new and base profiles
+type graphFunction struct {
+	name              string
+	flat, cum         int64
+	baseCum           int64
+	callees, baseEdge map[string]int64
+}
+
+// buildCallGraph keeps the functions that pass the node fraction and the
+// edges between them that pass the edge fraction. Without a base, the
+// functions with the largest cumulative values are kept; with a base, those
+// whose cumulative value changed most.
+func buildCallGraph(base, profile *parser.Profile, nodeFraction, edgeFraction float64) CallGraph {
+	total := profile.TotalSamples
+	if base != nil {
+		total = max(total, base.TotalSamples)
+	}
+	pct := func(v int64) float64 {
+		if profile.TotalSamples == 0 {
+			return 0
+		}
+		return float64(v) / float64(profile.TotalSamples) * 100
+	}
+	minNode := max(int64(float64(total)*nodeFraction), 1)
+	minEdge := max(int64(float64(total)*edgeFraction), 1)
+
+	byName := make(map[string]*graphFunction)
+	var names []string
+	get := func(name string) *graphFunction {
+		fn, ok := byName[name]
+		if !ok {
+			fn = &graphFunction{name: name, callees: make(map[string]int64), baseEdge: make(map[string]int64)}
+			byName[name] = fn
+			names = append(names, name)
+		}
+		return fn
+	}
+	for _, f := range profile.Functions {
+		fn := get(f.Name)
+		fn.flat, fn.cum = f.Flat, f.Cum
+		for _, e := range f.Callees {
+			fn.callees[e.Name] = e.Weight
+		}
+	}
+	if base != nil {
+		for _, f := range base.Functions {
+			fn := get(f.Name)
+			fn.baseCum = f.Cum
+			for _, e := range f.Callees {
+				fn.baseEdge[e.Name] = e.Weight
+			}
+		}
+	}
+
+	functions := make([]*graphFunction, 0, len(names))
+	for _, name := range names {
+		if fn := byName[name]; max(fn.cum, fn.baseCum) >= minNode {
+			functions = append(functions, fn)
+		}
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if base != nil && abs(a.cum-a.baseCum) != abs(b.cum-b.baseCum) {
+			return abs(a.cum-a.baseCum) > abs(b.cum-b.baseCum)
+		}
+		if a.cum != b.cum {
+			return a.cum > b.cum
+		}
+		return a.name < b.name
+	})
+	if len(functions) > maxGraphNodes {
+		functions = functions[:maxGraphNodes]
+	}
+
+	graph := CallGraph{Diff: base != nil}
+	ids := make(map[string]string, len(functions))
+	for i, fn := range functions {
+		node := GraphNode{
+			ID:     fmt.Sprintf("n%d", i+1),
+			Name:   fn.name,
+			Label:  shortenFrame(fn.name),
+			Flat:   fn.flat,
+			Cum:    fn.cum,
+			CumPct: pct(fn.cum),
+			Delta:  fn.cum - fn.baseCum,
+		}
+		switch {
+		case node.CumPct >= 25:
+			node.Heat = "hot"
+		case node.CumPct >= 5:
+			node.Heat = "warm"
+		default:
+			node.Heat = "cold"
+		}
+		ids[fn.name] = node.ID
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for _, fn := range functions {
+		callees := make([]string, 0, len(fn.callees)+len(fn.baseEdge))
+		for name := range fn.callees {
+			callees = append(callees, name)
+		}
+		for name := range fn.baseEdge {
+			if _, ok := fn.callees[name]; !ok {
+				callees = append(callees, name)
+			}
+		}
+		sort.Slice(callees, func(i, j int) bool {
+			wi, wj := fn.callees[callees[i]], fn.callees[callees[j]]
+			if wi != wj {
+				return wi > wj
+			}
+			return callees[i] < callees[j]
+		})
+
+		for _, callee := range callees {
+			to, ok := ids[callee]
+			weight, baseWeight := fn.callees[callee], fn.baseEdge[callee]
+			if !ok || max(weight, baseWeight) < minEdge {
+				continue
+			}
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From:   ids[fn.name],
+				To:     to,
+				Weight: weight,
+				Pct:    pct(weight),
+				Delta:  weight - baseWeight,
+				Heavy:  pct(weight) >= heavyEdgePct,
+			})
+		}
+	}
+	return graph
+}
+
+// mermaidLabel escapes a label for use inside a quoted Mermaid string
+func mermaidLabel(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
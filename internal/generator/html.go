@@ -0,0 +1,292 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// flameNode is a flame graph node in the compact JSON form read by the
+// HTML report's script
+type flameNode struct {
+	Name     string       `json:"n"`
+	Value    int64        `json:"v"`
+	Children []*flameNode `json:"c,omitempty"`
+}
+
+// summaryItem is one labelled value of the HTML summary
+type summaryItem struct {
+	Label string
+	Value string
+}
+
+// GenerateHTML generates a self-contained HTML report with the summary, the
+// top functions table and an interactive icicle graph of the sample stacks.
+// The page loads no external assets, so it can be shared as a single file.
+func (g *Generator) GenerateHTML() (string, error) {
+	if err := g.selectSampleType(); err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New("html").Parse(htmlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	functions, _ := g.applyLayout(g.defaultLayout())
+	rows := make([][]string, 0, len(functions))
+	for _, fn := range functions {
+		rows = append(rows, []string{
+			fmt.Sprint(fn.Rank),
+			fn.Name,
+			FormatLocation(fn.File, fn.Line),
+			FormatUnit(metricUnit(g.profile), fn.Flat),
+			fmt.Sprintf("%.2f%%", fn.FlatPct),
+			FormatUnit(metricUnit(g.profile), fn.Cum),
+			fmt.Sprintf("%.2f%%", fn.CumPct),
+		})
+	}
+
+	data := map[string]interface{}{
+		"Type":      string(g.profile.Type),
+		"Summary":   htmlSummary(g.profile),
+		"Functions": rows,
+		"Flame":     g.flameTree(),
+		"Unit":      metricUnit(g.profile),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// flameTree builds the flame graph from the profile's samples, children
+// ordered heaviest first
+func (g *Generator) flameTree() *flameNode {
+	root := newTreeNode()
+	var total int64
+	for _, s := range g.profile.Samples {
+		root.add(s.Stack, s.Value)
+		total += s.Value
+	}
+	root.name = "root"
+	root.weight = total
+	return toFlameNode(root)
+}
+
+// toFlameNode converts a call tree node and its descendants
+func toFlameNode(n *treeNode) *flameNode {
+	node := &flameNode{Name: n.name, Value: n.weight}
+	for _, child := range n.children {
+		if child.weight > 0 {
+			node.Children = append(node.Children, toFlameNode(child))
+		}
+	}
+	sort.Slice(node.Children, func(i, j int) bool {
+		if node.Children[i].Value != node.Children[j].Value {
+			return node.Children[i].Value > node.Children[j].Value
+		}
+		return node.Children[i].Name < node.Children[j].Name
+	})
+	return node
+}
+
+// htmlSummary returns the summary statistics shown at the top of the HTML
+// report, matching the markdown summary of each profile type
+func htmlSummary(p *parser.Profile) []summaryItem {
+	switch p.Type {
+	case parser.TypeCPU:
+		return []summaryItem{
+			{"Profile Duration", FormatDuration(p.Stats.TotalDuration.Nanoseconds())},
+			{"Total CPU Time", FormatDuration(p.TotalSamples)},
+			{"Sample Rate", fmt.Sprintf("%d Hz", p.Stats.SampleRate)},
+		}
+	case parser.TypeHeap:
+		return []summaryItem{
+			{"Allocated Objects", FormatNumber(p.Stats.AllocObjects)},
+			{"Allocated Bytes", FormatBytes(p.Stats.AllocBytes)},
+			{"In-Use Objects", FormatNumber(p.Stats.InUseObjects)},
+			{"In-Use Bytes", FormatBytes(p.Stats.InUseBytes)},
+		}
+	case parser.TypeGoroutine:
+		return []summaryItem{
+			{"Total Goroutines", FormatNumber(p.Stats.TotalGoroutines)},
+		}
+	case parser.TypeMutex:
+		return []summaryItem{
+			{"Total Contention Time", FormatDuration(p.Stats.TotalContentionTime)},
+			{"Total Waits", FormatNumber(p.Stats.TotalWaits)},
+		}
+	case parser.TypeSched, parser.TypeSyscall, parser.TypeNet, parser.TypeSync:
+		items := []summaryItem{
+			{"Total Wait Time", FormatDuration(p.Stats.TotalContentionTime)},
+			{"Events", FormatNumber(p.Stats.TotalWaits)},
+		}
+		if t := p.Trace; t != nil {
+			items = append(items,
+				summaryItem{"Trace Duration", FormatDuration(t.Duration.Nanoseconds())},
+				summaryItem{"GOMAXPROCS", fmt.Sprint(t.GOMAXPROCS)},
+				summaryItem{"Proc Utilization", fmt.Sprintf("%.1f%%", t.ProcUtilization)},
+				summaryItem{"GC Cycles", fmt.Sprint(t.GCCycles)},
+				summaryItem{"Stop-the-World Pauses", fmt.Sprintf("%d (max %s)", t.GCPauses, FormatDuration(t.GCPauseMax.Nanoseconds()))},
+			)
+		}
+		return items
+	default:
+		return []summaryItem{
+			{"Total Samples", fmt.Sprint(p.TotalSamples)},
+		}
+	}
+}
+
+// htmlTemplate is the HTML report. Styles and the icicle graph script are
+// inlined so the file works offline.
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Type }} Profile Analysis</title>
+<style>
+body { font: 14px/1.4 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1, h2 { font-weight: 600; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font: 12px Menlo, Consolas, monospace; }
+#controls { margin: 8px 0; }
+#controls input { width: 320px; padding: 4px; }
+#status { margin-left: 12px; color: #555; }
+#flame { position: relative; width: 100%; overflow: hidden; border: 1px solid #ddd; }
+#flame div { position: absolute; height: 17px; box-sizing: border-box; border: 1px solid #fff; padding: 0 3px; overflow: hidden; white-space: nowrap; font: 11px/15px Menlo, Consolas, monospace; cursor: pointer; }
+#flame div.match { background: #c77dff !important; }
+#flame div.faded { opacity: 0.45; }
+#tooltip { position: fixed; display: none; background: #fffbe6; border: 1px solid #aaa; padding: 4px 8px; font: 12px Menlo, Consolas, monospace; pointer-events: none; }
+</style>
+</head>
+<body>
+<h1>{{ .Type }} Profile Analysis</h1>
+
+<h2>Summary Statistics</h2>
+<table>
+{{- range .Summary }}
+<tr><th>{{ .Label }}</th><td class="num">{{ .Value }}</td></tr>
+{{- end }}
+</table>
+
+<h2>Flame Graph</h2>
+<p>Icicle view: callers above callees, width proportional to weight. Click a frame to zoom, click the top frame to zoom out.</p>
+<div id="controls"><input id="search" placeholder="Search functions (regexp)"><span id="status"></span></div>
+<div id="flame"></div>
+<div id="tooltip"></div>
+
+<h2>Top {{ .Type }} Functions</h2>
+<table>
+<tr><th>Rank</th><th>Function</th><th>Location</th><th>Flat</th><th>Flat %</th><th>Cumulative</th><th>Cumulative %</th></tr>
+{{- range .Functions }}
+<tr><td class="num">{{ index . 0 }}</td><td><code>{{ index . 1 }}</code></td><td><code>{{ index . 2 }}</code></td><td class="num">{{ index . 3 }}</td><td class="num">{{ index . 4 }}</td><td class="num">{{ index . 5 }}</td><td class="num">{{ index . 6 }}</td></tr>
+{{- end }}
+</table>
+
+<script>
+(function() {
+  var root = {{ .Flame }};
+  var unit = {{ .Unit }};
+  var rowHeight = 17;
+  var flame = document.getElementById("flame");
+  var tooltip = document.getElementById("tooltip");
+  var status = document.getElementById("status");
+  var focus = root, pattern = null;
+
+  function format(v) {
+    var units, scale;
+    if (unit === "nanoseconds") { units = ["ns", "µs", "ms", "s"]; scale = 1000; }
+    else if (unit === "bytes") { units = ["B", "KiB", "MiB", "GiB", "TiB"]; scale = 1024; }
+    else { units = ["", "K", "M", "G"]; scale = 1000; }
+    var i = 0;
+    while (Math.abs(v) >= scale && i < units.length - 1) { v /= scale; i++; }
+    return (i ? v.toFixed(2) : v) + (units[i] && unit !== "count" ? " " : "") + units[i];
+  }
+
+  function color(name) {
+    var h = 0;
+    for (var i = 0; i < name.length; i++) h = (h * 31 + name.charCodeAt(i)) >>> 0;
+    if (name.indexOf("runtime.") === 0) return "hsl(200, 45%, " + (70 + h % 10) + "%)";
+    return "hsl(" + (h % 50) + ", 80%, " + (60 + h % 15) + "%)";
+  }
+
+  function link(node, parent) {
+    node.p = parent;
+    (node.c || []).forEach(function(c) { link(c, node); });
+  }
+
+  function render() {
+    flame.innerHTML = "";
+    var width = flame.clientWidth, depth = 0, matched = 0;
+    var ancestors = [];
+    for (var n = focus.p; n; n = n.p) ancestors.unshift(n);
+    ancestors.forEach(function(n) { box(n, 0, width, depth++, true); });
+
+    function box(node, x, w, d, faded) {
+      var el = document.createElement("div");
+      el.style.left = x + "px";
+      el.style.width = w + "px";
+      el.style.top = d * rowHeight + "px";
+      el.style.background = color(node.n);
+      el.textContent = w > 30 ? node.n : "";
+      if (faded) el.className = "faded";
+      if (pattern && pattern.test(node.n)) { el.className += " match"; }
+      el.onclick = function() { focus = node === focus && node.p ? node.p : node; render(); };
+      el.onmousemove = function(e) {
+        tooltip.style.display = "block";
+        tooltip.style.left = e.clientX + 12 + "px";
+        tooltip.style.top = e.clientY + 12 + "px";
+        tooltip.textContent = node.n + " — " + format(node.v) + " (" + (100 * node.v / root.v).toFixed(2) + "%)";
+      };
+      el.onmouseout = function() { tooltip.style.display = "none"; };
+      flame.appendChild(el);
+      return el;
+    }
+
+    function draw(node, x, w, d) {
+      box(node, x, w, d, false);
+      if (pattern && pattern.test(node.n)) { matched += node.v; return Math.max(d, walk(node, x, w, d, true)); }
+      return walk(node, x, w, d, false);
+    }
+
+    function walk(node, x, w, d, inMatch) {
+      var max = d;
+      (node.c || []).forEach(function(c) {
+        var cw = w * c.v / node.v;
+        if (cw >= 0.5) {
+          var before = matched;
+          max = Math.max(max, draw(c, x, cw, d + 1));
+          if (inMatch) matched = before;
+        }
+        x += cw;
+      });
+      return max;
+    }
+
+    var maxDepth = draw(focus, 0, width, depth);
+    flame.style.height = (maxDepth + 1) * rowHeight + "px";
+    status.textContent = pattern ? "Matched: " + format(matched) + " (" + (100 * matched / root.v).toFixed(2) + "%)" : "";
+  }
+
+  link(root, null);
+  document.getElementById("search").oninput = function(e) {
+    try { pattern = e.target.value ? new RegExp(e.target.value) : null; } catch (err) { pattern = null; }
+    render();
+  };
+  window.onresize = render;
+  render();
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// JSONSchemaVersion is the version of the JSON report. It is incremented
+// when a field is removed or changes meaning; adding fields does not
+// change it.
+const JSONSchemaVersion = 1
+
+// JSONReport is the JSON form of a markdown report. Values are in Unit;
+// time values are nanoseconds.
+type JSONReport struct {
+	Version   int              `json:"version"`
+	Type      string           `json:"type"`
+	Metric    string           `json:"metric"` // Sample type of the values, e.g. "cpu" or "inuse_space"
+	Unit      string           `json:"unit"`
+	Total     int64            `json:"total"`
+	Stats     map[string]int64 `json:"stats,omitempty"` // Type-specific summary statistics
+	Trace     *JSONTrace       `json:"trace,omitempty"`
+	Functions []JSONFunction   `json:"functions"`
+}
+
+// JSONTrace is the summary of the execution trace a profile was derived from
+type JSONTrace struct {
+	DurationNanos     int64   `json:"duration_ns"`
+	GOMAXPROCS        int     `json:"gomaxprocs"`
+	Goroutines        int     `json:"goroutines"`
+	GCCycles          int     `json:"gc_cycles"`
+	GCPauses          int     `json:"gc_pauses"`
+	GCPauseTotalNanos int64   `json:"gc_pause_total_ns"`
+	GCPauseMaxNanos   int64   `json:"gc_pause_max_ns"`
+	ProcUtilization   float64 `json:"proc_utilization_pct"`
+}
+
+// JSONFunction is one of the top functions of a JSON report
+type JSONFunction struct {
+	Rank      int            `json:"rank"`
+	Name      string         `json:"name"`
+	File      string         `json:"file,omitempty"`
+	Line      int            `json:"line,omitempty"`
+	Flat      int64          `json:"flat"`
+	FlatPct   float64        `json:"flat_pct"`
+	Cum       int64          `json:"cum"`
+	CumPct    float64        `json:"cum_pct"`
+	CallPaths []JSONCallPath `json:"call_paths,omitempty"`
+	Callers   []JSONEdge     `json:"callers,omitempty"`
+	Callees   []JSONEdge     `json:"callees,omitempty"`
+	Lines     []JSONLine     `json:"lines,omitempty"` // Sampled source lines, heaviest first
+}
+
+// JSONLine is the weight sampled at one source line of a function
+type JSONLine struct {
+	Line int   `json:"line"`
+	Flat int64 `json:"flat"`
+	Cum  int64 `json:"cum"`
+}
+
+// JSONCallPath is a call path to a function, root first
+type JSONCallPath struct {
+	Stack  []string `json:"stack"`
+	Weight int64    `json:"weight"`
+}
+
+// JSONEdge is the weight flowing from or to an immediate caller or callee
+type JSONEdge struct {
+	Name   string `json:"name"`
+	Weight int64  `json:"weight"`
+}
+
+// GenerateJSON generates the report as JSON: the summary statistics and the
+// top functions with their call paths, callers and callees. Call paths
+// follow WithMaxPaths and WithPathThreshold; WithMaxTokens does not apply.
+func (g *Generator) GenerateJSON() (string, error) {
+	report, err := g.Report()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+	return string(out) + "\n", nil
+}
+
+// Report returns the report that GenerateJSON encodes
+func (g *Generator) Report() (JSONReport, error) {
+	if err := g.selectSampleType(); err != nil {
+		return JSONReport{}, err
+	}
+	return g.jsonReport(), nil
+}
+
+// jsonReport builds the JSON report of the profile
+func (g *Generator) jsonReport() JSONReport {
+	metric := g.profile.Metric.Type
+	if metric == "" {
+		metric = string(g.profile.Type)
+	}
+
+	report := JSONReport{
+		Version:   JSONSchemaVersion,
+		Type:      string(g.profile.Type),
+		Metric:    metric,
+		Unit:      metricUnit(g.profile),
+		Total:     g.profile.TotalSamples,
+		Stats:     jsonStats(g.profile),
+		Functions: []JSONFunction{},
+	}
+	if t := g.profile.Trace; t != nil {
+		report.Trace = &JSONTrace{
+			DurationNanos:     t.Duration.Nanoseconds(),
+			GOMAXPROCS:        t.GOMAXPROCS,
+			Goroutines:        t.Goroutines,
+			GCCycles:          t.GCCycles,
+			GCPauses:          t.GCPauses,
+			GCPauseTotalNanos: t.GCPauseTotal.Nanoseconds(),
+			GCPauseMaxNanos:   t.GCPauseMax.Nanoseconds(),
+			ProcUtilization:   t.ProcUtilization,
+		}
+	}
+
+	functions, _ := g.applyLayout(g.defaultLayout())
+	for _, fn := range functions {
+		jf := JSONFunction{
+			Rank:    fn.Rank,
+			Name:    fn.Name,
+			File:    fn.File,
+			Line:    fn.Line,
+			Flat:    fn.Flat,
+			FlatPct: fn.FlatPct,
+			Cum:     fn.Cum,
+			CumPct:  fn.CumPct,
+			Callers: jsonEdges(fn.Callers),
+			Callees: jsonEdges(fn.Callees),
+		}
+		for _, lv := range fn.Lines {
+			jf.Lines = append(jf.Lines, JSONLine{Line: lv.Line, Flat: lv.Flat, Cum: lv.Cum})
+		}
+		for _, path := range fn.CallPaths {
+			jf.CallPaths = append(jf.CallPaths, JSONCallPath{Stack: path.Stack, Weight: path.Weight})
+		}
+		report.Functions = append(report.Functions, jf)
+	}
+	return report
+}
+
+// jsonStats returns the summary statistics of the profile type, matching
+// the markdown summary
+func jsonStats(p *parser.Profile) map[string]int64 {
+	switch p.Type {
+	case parser.TypeCPU:
+		return map[string]int64{
+			"duration_ns":    p.Stats.TotalDuration.Nanoseconds(),
+			"sample_rate_hz": p.Stats.SampleRate,
+		}
+	case parser.TypeHeap:
+		return map[string]int64{
+			"alloc_objects": p.Stats.AllocObjects,
+			"alloc_bytes":   p.Stats.AllocBytes,
+			"inuse_objects": p.Stats.InUseObjects,
+			"inuse_bytes":   p.Stats.InUseBytes,
+		}
+	case parser.TypeGoroutine:
+		return map[string]int64{
+			"goroutines": p.Stats.TotalGoroutines,
+		}
+	case parser.TypeMutex, parser.TypeSched, parser.TypeSyscall, parser.TypeNet, parser.TypeSync:
+		return map[string]int64{
+			"delay_ns":    p.Stats.TotalContentionTime,
+			"contentions": p.Stats.TotalWaits,
+		}
+	default:
+		return nil
+	}
+}
+
+// jsonEdges converts caller or callee edges
+func jsonEdges(edges []parser.Edge) []JSONEdge {
+	var result []JSONEdge
+	for _, e := range edges {
+		result = append(result, JSONEdge{Name: e.Name, Weight: e.Weight})
+	}
+	return result
+}
@@ -0,0 +1,330 @@
+package generator
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// HeapLeak is an allocation site whose in-use memory grew across snapshots
+type HeapLeak struct {
+	Name    string // Leaf function of the allocation stack
+	File    string
+	Line    int
+	Stack   []string // Allocation stack, root first
+	Bytes   Series   // inuse_space per snapshot
+	Objects Series   // inuse_objects per snapshot
+
+	BytesRate   int64 // In-use bytes growth per minute (or per snapshot)
+	ObjectsRate int64 // In-use objects growth per minute (or per snapshot)
+}
+
+// timeAxis returns the x coordinate of every profile: minutes since the
+// first profile when all profiles carry timestamps, and the profile index
+// otherwise. The returned unit names the rate denominator.
+func timeAxis(profiles []*parser.Profile) ([]float64, string) {
+	xs := make([]float64, len(profiles))
+	for i := range xs {
+		xs[i] = float64(i)
+	}
+	if len(profiles) == 0 {
+		return xs, "snapshot"
+	}
+	for _, p := range profiles {
+		if p.Time.IsZero() {
+			return xs, "snapshot"
+		}
+	}
+	// Snapshots taken at the same instant carry no rate information
+	first := profiles[0].Time
+	if profiles[len(profiles)-1].Time.Sub(first) <= 0 {
+		return xs, "snapshot"
+	}
+	for i, p := range profiles {
+		xs[i] = p.Time.Sub(first).Minutes()
+	}
+	return xs, "min"
+}
+
+// computeHeapLeaks finds allocation sites whose in-use bytes never decreased
+// and ended higher than they started, ranked by growth rate. The second
+// result is false when the profiles carry no in-use data.
+func (g *TrendGenerator) computeHeapLeaks() ([]HeapLeak, bool) {
+	spaceIdx := make([]int, len(g.points))
+	objectsIdx := make([]int, len(g.points))
+	for i, p := range g.points {
+		spaceIdx[i] = p.Profile.SampleTypeIndex("inuse_space")
+		objectsIdx[i] = p.Profile.SampleTypeIndex("inuse_objects")
+		if spaceIdx[i] < 0 {
+			return nil, false
+		}
+	}
+
+	sites := make(map[string]*HeapLeak)
+	var keys []string
+	for i, p := range g.points {
+		for _, s := range p.Profile.Samples {
+			if len(s.Stack) == 0 || spaceIdx[i] >= len(s.Values) {
+				continue
+			}
+			key := strings.Join(s.Stack, "\x00")
+			site, ok := sites[key]
+			if !ok {
+				site = &HeapLeak{
+					Name:    s.Stack[len(s.Stack)-1],
+					Stack:   s.Stack,
+					Bytes:   Series{Name: "In-Use Bytes", Unit: "bytes", Values: make([]int64, len(g.points))},
+					Objects: Series{Name: "In-Use Objects", Unit: "count", Values: make([]int64, len(g.points))},
+				}
+				sites[key] = site
+				keys = append(keys, key)
+			}
+			site.Bytes.Values[i] += s.Values[spaceIdx[i]]
+			if objectsIdx[i] >= 0 && objectsIdx[i] < len(s.Values) {
+				site.Objects.Values[i] += s.Values[objectsIdx[i]]
+			}
+		}
+	}
+
+	// Resolve source locations from the most recent snapshot that has them
+	locations := g.functionLocations()
+
+	xs, _ := timeAxis(g.profiles())
+	var leaks []HeapLeak
+	for _, key := range keys {
+		site := sites[key]
+		if !site.Bytes.Increasing() {
+			continue
+		}
+		if fn, ok := locations[site.Name]; ok {
+			site.File = fn.File
+			site.Line = fn.Line
+		}
+		site.BytesRate = int64(math.Round(slope(xs, site.Bytes.Values)))
+		site.ObjectsRate = int64(math.Round(slope(xs, site.Objects.Values)))
+		leaks = append(leaks, *site)
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].BytesRate != leaks[j].BytesRate {
+			return leaks[i].BytesRate > leaks[j].BytesRate
+		}
+		return leaks[i].Name < leaks[j].Name
+	})
+	if len(leaks) > g.topN {
+		leaks = leaks[:g.topN]
+	}
+	return leaks, true
+}
+
+// profiles returns the profiles of every point in order
+func (g *TrendGenerator) profiles() []*parser.Profile {
+	profiles := make([]*parser.Profile, len(g.points))
+	for i, p := range g.points {
+		profiles[i] = p.Profile
+	}
+	return profiles
+}
+
+// functionLocations maps function names to their entry in the most recent
+// snapshot that contains them
+func (g *TrendGenerator) functionLocations() map[string]parser.Function {
+	locations := make(map[string]parser.Function)
+	for i := len(g.points) - 1; i >= 0; i-- {
+		for _, fn := range g.points[i].Profile.Functions {
+			if _, ok := locations[fn.Name]; !ok {
+				locations[fn.Name] = fn
+			}
+		}
+	}
+	return locations
+}
+
+// GoroutineLeak is a goroutine stack whose count grew across snapshots
+type GoroutineLeak struct {
+	Name      string   // Leaf function where the goroutines are parked
+	CreatedBy string   // Creation site of the goroutines
+	Inferred  bool     // CreatedBy is the entry function; no "created by" was recorded
+	Stack     []string // Goroutine stack, root first
+	Count     Series   // Goroutines per snapshot
+	Rate      float64  // Growth per minute (or per snapshot)
+}
+
+// CreationSiteGrowth aggregates growing goroutine stacks by creation site
+type CreationSiteGrowth struct {
+	CreatedBy string
+	Inferred  bool    // CreatedBy is the entry function; no "created by" was recorded
+	Stacks    int     // Number of distinct growing stacks
+	Count     Series  // Goroutines per snapshot across those stacks
+	Rate      float64 // Growth per minute (or per snapshot)
+}
+
+// detectGoroutineLeaks finds goroutine stacks whose count never decreased
+// and ended higher than it started, ranked by growth rate, together with
+// the same growth aggregated by creation site
+func detectGoroutineLeaks(profiles []*parser.Profile, topN int) ([]GoroutineLeak, []CreationSiteGrowth) {
+	stacks := make(map[string]*GoroutineLeak)
+	var keys []string
+	for i, p := range profiles {
+		for _, s := range p.Samples {
+			if len(s.Stack) == 0 {
+				continue
+			}
+			key := s.CreatedBy + "\x00" + strings.Join(s.Stack, "\x00")
+			leak, ok := stacks[key]
+			if !ok {
+				site, inferred := sampleCreationSite(s)
+				leak = &GoroutineLeak{
+					Name:      s.Stack[len(s.Stack)-1],
+					CreatedBy: site,
+					Inferred:  inferred,
+					Stack:     s.Stack,
+					Count:     Series{Name: "Goroutines", Unit: "count", Values: make([]int64, len(profiles))},
+				}
+				stacks[key] = leak
+				keys = append(keys, key)
+			}
+			leak.Count.Values[i] += s.Value
+		}
+	}
+
+	xs, _ := timeAxis(profiles)
+	var leaks []GoroutineLeak
+	sites := make(map[string]*CreationSiteGrowth)
+	var siteNames []string
+	for _, key := range keys {
+		leak := stacks[key]
+		if !leak.Count.Increasing() {
+			continue
+		}
+		leak.Rate = slope(xs, leak.Count.Values)
+		leaks = append(leaks, *leak)
+
+		// A recorded site and an entry function of the same name differ
+		siteKey := leak.CreatedBy
+		if leak.Inferred {
+			siteKey += "\x00entry"
+		}
+		site, ok := sites[siteKey]
+		if !ok {
+			site = &CreationSiteGrowth{
+				CreatedBy: leak.CreatedBy,
+				Inferred:  leak.Inferred,
+				Count:     Series{Name: "Goroutines", Unit: "count", Values: make([]int64, len(profiles))},
+			}
+			sites[siteKey] = site
+			siteNames = append(siteNames, siteKey)
+		}
+		site.Stacks++
+		site.Rate += leak.Rate
+		for i, v := range leak.Count.Values {
+			site.Count.Values[i] += v
+		}
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Rate != leaks[j].Rate {
+			return leaks[i].Rate > leaks[j].Rate
+		}
+		return leaks[i].Name < leaks[j].Name
+	})
+	if len(leaks) > topN {
+		leaks = leaks[:topN]
+	}
+
+	growth := make([]CreationSiteGrowth, 0, len(siteNames))
+	for _, name := range siteNames {
+		growth = append(growth, *sites[name])
+	}
+	sort.Slice(growth, func(i, j int) bool {
+		if growth[i].Rate != growth[j].Rate {
+			return growth[i].Rate > growth[j].Rate
+		}
+		return growth[i].CreatedBy < growth[j].CreatedBy
+	})
+	if len(growth) > topN {
+		growth = growth[:topN]
+	}
+
+	return leaks, growth
+}
+
+// goroutineLeaksTemplate renders the output of detectGoroutineLeaks; it
+// expects GoroutineLeaks, CreationSites and RateUnit in the template data
+const goroutineLeaksTemplate = `
+{{- define "goroutine-leaks" }}
+{{- if .GoroutineLeaks }}
+Goroutine stacks whose count never decreased between snapshots and ended higher than it started, grouped by the ` + "`" + `created by` + "`" + ` site that started them. Binary goroutine profiles record no ` + "`" + `created by` + "`" + ` site, so their goroutines are grouped by the function they run, marked _(entry function)_.
+
+### Growth by Creation Site
+
+| Rank | Creation Site | Stacks | Goroutines | Growth/{{ .RateUnit }} | Trend |
+|------|---------------|--------|------------|-------------|-------|
+{{- range $i, $s := .CreationSites }}
+| {{ add $i 1 }} | ` + "`" + `{{ $s.CreatedBy }}` + "`" + `{{ if $s.Inferred }} _(entry function)_{{ end }} | {{ $s.Stacks }} | {{ $s.Count.First }} → {{ $s.Count.Last }} | {{ printf "%+.1f" $s.Rate }} | ` + "`" + `{{ $s.Count.Sparkline }}` + "`" + ` |
+{{- end }}
+
+### Growing Stacks
+
+| Rank | Blocked In | Creation Site | Goroutines | Growth/{{ .RateUnit }} | Trend |
+|------|------------|---------------|------------|-------------|-------|
+{{- range $i, $l := .GoroutineLeaks }}
+| {{ add $i 1 }} | ` + "`" + `{{ $l.Name }}` + "`" + ` | ` + "`" + `{{ $l.CreatedBy }}` + "`" + `{{ if $l.Inferred }} _(entry function)_{{ end }} | {{ $l.Count.First }} → {{ $l.Count.Last }} | {{ printf "%+.1f" $l.Rate }} | ` + "`" + `{{ $l.Count.Sparkline }}` + "`" + ` |
+{{- end }}
+{{- range $i, $l := .GoroutineLeaks }}
+
+#### {{ add $i 1 }}. {{ $l.CreatedBy }}{{ if $l.Inferred }} (entry function){{ end }} → {{ $l.Name }}
+
+` + "```" + `
+{{ formatStack $l.Stack }}
+` + "```" + `
+{{- end }}
+{{- else }}
+_No goroutine stack grew in every snapshot._
+{{- end }}
+{{- end }}
+`
+
+// slope returns the least-squares slope of ys over xs
+func slope(xs []float64, ys []int64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += float64(ys[i])
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var num, den float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		num += dx * (float64(ys[i]) - meanY)
+		den += dx * dx
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+// formatStack renders a root-first stack leaf first, marking the leaf frame
+func formatStack(stack []string) string {
+	var sb strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		if i == len(stack)-1 {
+			sb.WriteString("  → ")
+		} else {
+			sb.WriteString("    ")
+		}
+		sb.WriteString(stack[i])
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
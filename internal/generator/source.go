@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// ErrSourceNotFound is returned by SourceListing when the function's source
+// file is neither at its recorded path nor under the source directory
+var ErrSourceNotFound = errors.New("source file not found")
+
+// SourceListing renders the source around a function's hottest sampled
+// line as a go code block headed by its location and values. Sampled lines
+// are marked "•" and annotated with their flat and cumulative values in
+// unit, the hottest one is marked "→", and sampled lines outside the window
+// are listed after the block. Files are looked up as recorded, then under
+// sourceDir (see ResolveSource).
+func SourceListing(fn parser.Function, unit, sourceDir string, around int) (string, error) {
+	if fn.File == "" {
+		return "", fmt.Errorf("no source location recorded for %s", fn.Name)
+	}
+	path := ResolveSource(fn.File, sourceDir)
+	if path == "" {
+		return "", fmt.Errorf("%w: %s", ErrSourceNotFound, fn.File)
+	}
+
+	// Centre on the hottest sampled line; fn.Line is just the first seen
+	hot := fn.Line
+	sampled := make(map[int]parser.LineValue, len(fn.Lines))
+	for _, lv := range fn.Lines {
+		sampled[lv.Line] = lv
+	}
+	if len(fn.Lines) > 0 {
+		hot = fn.Lines[0].Line
+	}
+	lines, err := readLines(path, hot-around, hot+around)
+	if err != nil {
+		return "", err
+	}
+	values := func(lv parser.LineValue) string {
+		return fmt.Sprintf("flat %s, cum %s", FormatUnit(unit, lv.Flat), FormatUnit(unit, lv.Cum))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (flat %s, cumulative %s)\n\n```go\n", FormatLocation(path, hot),
+		FormatUnit(unit, fn.Flat), FormatUnit(unit, fn.Cum))
+	shown := make(map[int]bool)
+	for _, l := range lines {
+		marker, note := " ", ""
+		if lv, ok := sampled[l.number]; ok {
+			marker, note = "•", "  // "+values(lv)
+			shown[l.number] = true
+		}
+		if l.number == hot {
+			marker = "→"
+		}
+		fmt.Fprintf(&b, "%s %5d  %s%s\n", marker, l.number, l.text, note)
+	}
+	b.WriteString("```\n")
+
+	var others []string
+	for _, lv := range fn.Lines {
+		if !shown[lv.Line] {
+			others = append(others, fmt.Sprintf("line %d (%s)", lv.Line, values(lv)))
+		}
+	}
+	if len(others) > 0 {
+		fmt.Fprintf(&b, "\nOther sampled lines: %s\n", strings.Join(others, "; "))
+	}
+	return b.String(), nil
+}
+
+// ResolveSource finds a source file recorded in a profile: as recorded, or
+// under sourceDir with as many leading directories dropped as needed.
+// It returns "" if the file is not found.
+func ResolveSource(file, sourceDir string) string {
+	if _, err := os.Stat(file); err == nil {
+		return file
+	}
+	if sourceDir == "" {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	for i := range parts {
+		candidate := filepath.Join(sourceDir, filepath.Join(parts[i:]...))
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// sourceLine is a numbered line of a source file
+type sourceLine struct {
+	number int
+	text   string
+}
+
+// readLines reads lines from through to of a file, clamped to the file
+func readLines(path string, from, to int) ([]sourceLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer f.Close()
+
+	var lines []sourceLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= to; n++ {
+		if n >= from {
+			lines = append(lines, sourceLine{number: n, text: scanner.Text()})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+	return lines, nil
+}
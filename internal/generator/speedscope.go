@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// speedscopeSchema identifies the speedscope file format
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// speedscopeFile is a speedscope file with shared frames
+type speedscopeFile struct {
+	Schema             string              `json:"$schema"`
+	Name               string              `json:"name"`
+	Exporter           string              `json:"exporter"`
+	ActiveProfileIndex int                 `json:"activeProfileIndex"`
+	Shared             speedscopeShared    `json:"shared"`
+	Profiles           []speedscopeProfile `json:"profiles"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// speedscopeProfile is a "sampled" profile: each sample is a stack of frame
+// indices, root first, with a matching weight
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+// speedscopeSeries is a sample value to export as one profile
+type speedscopeSeries struct {
+	name  string
+	unit  string
+	value func(parser.Sample) int64
+}
+
+// GenerateSpeedscope generates a speedscope JSON file of the sample stacks.
+// Heap profiles get one profile per sample type (allocations and in-use
+// space and objects); other profiles get one for the primary metric, or for
+// the sample type selected with WithSampleIndex.
+func (g *Generator) GenerateSpeedscope() (string, error) {
+	series, err := g.speedscopeSeries()
+	if err != nil {
+		return "", err
+	}
+
+	locations := make(map[string]parser.Function, len(g.profile.Functions))
+	for _, fn := range g.profile.Functions {
+		locations[fn.Name] = fn
+	}
+
+	file := speedscopeFile{
+		Schema:   speedscopeSchema,
+		Name:     fmt.Sprintf("%s profile", g.profile.Type),
+		Exporter: "go-pprof-md",
+	}
+	frames := make(map[string]int)
+	frameIndex := func(name string) int {
+		i, ok := frames[name]
+		if !ok {
+			i = len(file.Shared.Frames)
+			frames[name] = i
+			fn := locations[name]
+			file.Shared.Frames = append(file.Shared.Frames, speedscopeFrame{Name: name, File: fn.File, Line: fn.Line})
+		}
+		return i
+	}
+
+	for _, s := range series {
+		profile := speedscopeProfile{
+			Type:    "sampled",
+			Name:    s.name,
+			Unit:    speedscopeUnit(s.unit),
+			Samples: [][]int{},
+			Weights: []int64{},
+		}
+		for _, sample := range g.profile.Samples {
+			weight := s.value(sample)
+			if weight == 0 || len(sample.Stack) == 0 {
+				continue
+			}
+			stack := make([]int, len(sample.Stack))
+			for i, frame := range sample.Stack {
+				stack[i] = frameIndex(frame)
+			}
+			profile.Samples = append(profile.Samples, stack)
+			profile.Weights = append(profile.Weights, weight)
+			profile.EndValue += weight
+		}
+		file.Profiles = append(file.Profiles, profile)
+	}
+	if file.Shared.Frames == nil {
+		file.Shared.Frames = []speedscopeFrame{}
+	}
+
+	out, err := json.Marshal(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode speedscope file: %w", err)
+	}
+	return string(out), nil
+}
+
+// speedscopeSeries returns the sample values to export
+func (g *Generator) speedscopeSeries() ([]speedscopeSeries, error) {
+	if g.sampleIndex == "" && g.profile.Type == parser.TypeHeap && len(g.profile.SampleTypes) > 0 {
+		series := make([]speedscopeSeries, 0, len(g.profile.SampleTypes))
+		for i, st := range g.profile.SampleTypes {
+			series = append(series, speedscopeSeries{name: st.Type, unit: st.Unit, value: func(s parser.Sample) int64 {
+				if i >= len(s.Values) {
+					return 0
+				}
+				return s.Values[i]
+			}})
+		}
+		return series, nil
+	}
+
+	// The selected sample type is read and scaled as in the markdown report
+	if err := g.selectSampleType(); err != nil {
+		return nil, err
+	}
+	name := g.profile.Metric.Type
+	if name == "" {
+		name = string(g.profile.Type)
+	}
+	return []speedscopeSeries{{
+		name:  name,
+		unit:  metricUnit(g.profile),
+		value: func(s parser.Sample) int64 { return s.Value },
+	}}, nil
+}
+
+// speedscopeUnit maps a pprof unit to a speedscope value unit
+func speedscopeUnit(unit string) string {
+	switch unit {
+	case "nanoseconds", "microseconds", "milliseconds", "seconds", "bytes":
+		return unit
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,338 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// DefaultPartSize is the default maximum size of a split report part in bytes
+const DefaultPartSize = 100_000
+
+// sourceContext is the number of lines shown before and after the hottest
+// line of each function in the source listings of a split report
+const sourceContext = 5
+
+// Part is one file of a split report
+type Part struct {
+	Name     string // File name, e.g. "02-top-functions.md"
+	Contents string // Short description used in the index
+	Body     string
+}
+
+// GenerateSplit generates the report as an index document followed by parts
+// for the summary, the top functions table, call paths per package and the
+// source around each function's hottest lines, grouped by file (see
+// WithSourceDir). Parts are split further so that each stays under maxBytes
+// where possible; a single section larger than maxBytes gets a part of its
+// own. The index is always the first part. WithMaxTokens does not apply;
+// maxBytes bounds each part instead.
+func (g *Generator) GenerateSplit(maxBytes int) ([]Part, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultPartSize
+	}
+	if err := g.selectSampleType(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := g.getTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	layout := g.defaultLayout()
+	data := g.prepareTemplateData(layout, g.buildSections(layout))
+	functions := data["Functions"].([]FunctionView)
+	title := fmt.Sprintf("# %s Profile Analysis", g.profile.Type)
+
+	var parts []Part
+	add := func(slug, contents string, bodies []string) {
+		for i, body := range bodies {
+			p := Part{Name: slug, Contents: contents, Body: body}
+			if len(bodies) > 1 {
+				p.Name = fmt.Sprintf("%s-%d", slug, i+1)
+				p.Contents = fmt.Sprintf("%s (%d of %d)", contents, i+1, len(bodies))
+			}
+			parts = append(parts, p)
+		}
+	}
+
+	// Summary
+	summary, err := executeSections(tmpl, data, "summary", "creation-sites")
+	if err != nil {
+		return nil, err
+	}
+	add("summary", "Summary statistics", []string{title + ": Summary" + summary + "\n"})
+
+	// Top functions table, one row per function
+	header, err := executeSections(tmpl, data, "top-functions-header")
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]string, 0, len(functions))
+	for _, fn := range functions {
+		row, err := executeSections(tmpl, fn, "function-row")
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	add("top-functions", "Top functions", packSections(title+": Top Functions"+header, rows, "\n", maxBytes))
+
+	// Call paths, grouped by package
+	for _, pkg := range groupByPackage(functions) {
+		var sections []string
+		for _, fn := range pkg.functions {
+			section, err := executeSections(tmpl, fn, "function-paths")
+			if err != nil {
+				return nil, err
+			}
+			if section != "" {
+				sections = append(sections, section)
+			}
+		}
+		if len(sections) == 0 {
+			continue
+		}
+		add("paths-"+slugify(pkg.name), "Call paths in `"+pkg.name+"`",
+			packSections("# Call Paths: "+pkg.name, sections, "\n", maxBytes))
+	}
+
+	// Flame graph
+	if data["FlameGraph"] != nil {
+		flame, err := executeSections(tmpl, data, "flame-graph")
+		if err != nil {
+			return nil, err
+		}
+		add("flame-graph", "Flame graph", []string{title + ": Flame Graph" + flame + "\n"})
+	}
+
+	// Call graph
+	if data["CallGraph"] != nil {
+		graph, err := executeSections(tmpl, data, "mermaid")
+		if err != nil {
+			return nil, err
+		}
+		add("call-graph", "Call graph (Mermaid)", []string{title + ": Call Graph" + graph + "\n"})
+	}
+
+	// Callers and callees
+	if g.includePeek {
+		var peeks []string
+		for _, fn := range functions {
+			section, err := executeSections(tmpl, fn, "function-peek")
+			if err != nil {
+				return nil, err
+			}
+			peeks = append(peeks, section)
+		}
+		add("callers-callees", "Callers and callees", packSections("# Callers and Callees", peeks, "\n", maxBytes))
+	}
+
+	// Call trees
+	if data["CallTree"] != nil {
+		tree, err := executeSections(tmpl, data, "call-tree")
+		if err != nil {
+			return nil, err
+		}
+		add("call-tree", "Top-down call tree", []string{title + ": Call Tree" + tree + "\n"})
+
+		var trees []string
+		for _, fn := range functions {
+			section, err := executeSections(tmpl, fn, "caller-tree")
+			if err != nil {
+				return nil, err
+			}
+			if section != "" {
+				trees = append(trees, section)
+			}
+		}
+		add("caller-trees", "Caller trees", packSections("# Caller Trees", trees, "\n", maxBytes))
+	}
+
+	// Source listings, grouped by file
+	var listings []string
+	for _, file := range groupByFile(functions) {
+		for _, fn := range file.functions {
+			listings = append(listings, g.sourceSection(fn.Function))
+		}
+	}
+	if len(listings) > 0 {
+		add("sources", "Source listings by file", packSections("# Source Listings", listings, "\n", maxBytes))
+	}
+
+	// Number parts in reading order
+	width := len(fmt.Sprint(len(parts)))
+	if width < 2 {
+		width = 2
+	}
+	for i := range parts {
+		parts[i].Name = fmt.Sprintf("%0*d-%s.md", width, i+1, parts[i].Name)
+	}
+
+	index := g.splitIndex(title, parts, maxBytes, data)
+	return append([]Part{{Name: fmt.Sprintf("%0*d-index.md", width, 0), Contents: "Index", Body: index}}, parts...), nil
+}
+
+// splitIndex renders the index document linking every part
+func (g *Generator) splitIndex(title string, parts []Part, maxBytes int, data map[string]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(title + ": Index\n\n")
+	fmt.Fprintf(&sb, "This report is split into %d parts of at most %s each. Read them in order.\n\n", len(parts), FormatBytes(int64(maxBytes)))
+	sb.WriteString("| Part | Contents | Size |\n")
+	sb.WriteString("|------|----------|------|\n")
+	for _, p := range parts {
+		fmt.Fprintf(&sb, "| [%s](%s) | %s | %s |\n", p.Name, p.Name, p.Contents, FormatBytes(int64(len(p.Body))))
+	}
+	if g.includeAIPrompt {
+		sb.WriteString("\n")
+		sb.WriteString(data["AIAnalysisPrompt"].(string))
+	}
+	return sb.String()
+}
+
+// executeSections renders the named templates one after another
+func executeSections(tmpl *template.Template, data interface{}, names ...string) (string, error) {
+	var buf bytes.Buffer
+	for _, name := range names {
+		if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", fmt.Errorf("failed to execute template %s: %w", name, err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// packSections groups sections into chunks that start with header and stay
+// under maxBytes. Sections are never split, so a section that alone exceeds
+// maxBytes gets a chunk of its own.
+func packSections(header string, sections []string, trailer string, maxBytes int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, section := range sections {
+		if current.Len() > 0 && current.Len()+len(section)+len(trailer) > maxBytes {
+			current.WriteString(trailer)
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() == 0 {
+			current.WriteString(header)
+		}
+		current.WriteString(section)
+	}
+	if current.Len() == 0 {
+		current.WriteString(header)
+	}
+	current.WriteString(trailer)
+	return append(chunks, current.String())
+}
+
+// packageFunctions is the set of reported functions in one package
+type packageFunctions struct {
+	name      string
+	functions []FunctionView
+}
+
+// groupByPackage groups functions by Go package, keeping the order in which
+// packages first appear
+func groupByPackage(functions []FunctionView) []packageFunctions {
+	var groups []packageFunctions
+	index := make(map[string]int)
+	for _, fn := range functions {
+		pkg := packageName(fn.Name)
+		i, ok := index[pkg]
+		if !ok {
+			i = len(groups)
+			index[pkg] = i
+			groups = append(groups, packageFunctions{name: pkg})
+		}
+		groups[i].functions = append(groups[i].functions, fn)
+	}
+	return groups
+}
+
+// sourceSection renders the source listing of one function for a split
+// report, or a note when its source cannot be read
+func (g *Generator) sourceSection(fn parser.Function) string {
+	header := fmt.Sprintf("\n\n### `%s`\n\n", fn.Name)
+	listing, err := SourceListing(fn, metricUnit(g.profile), g.sourceDir, sourceContext)
+	if err != nil {
+		return header + fmt.Sprintf("_%s: %s._\n", FormatLocation(fn.File, fn.Line), sourceError(err))
+	}
+	return header + listing
+}
+
+// sourceError describes why a source listing is missing
+func sourceError(err error) string {
+	if errors.Is(err, ErrSourceNotFound) {
+		return "source file not found; set the source directory to the checkout the binary was built from"
+	}
+	return err.Error()
+}
+
+// fileFunctions is the set of reported functions defined in one file
+type fileFunctions struct {
+	file      string
+	functions []FunctionView
+}
+
+// groupByFile groups functions by source file, ordered by file name, with
+// functions ordered by line
+func groupByFile(functions []FunctionView) []fileFunctions {
+	byFile := make(map[string]*fileFunctions)
+	var names []string
+	for _, fn := range functions {
+		if fn.File == "" {
+			continue
+		}
+		file, ok := byFile[fn.File]
+		if !ok {
+			file = &fileFunctions{file: fn.File}
+			byFile[fn.File] = file
+			names = append(names, fn.File)
+		}
+		file.functions = append(file.functions, fn)
+	}
+	sort.Strings(names)
+
+	result := make([]fileFunctions, 0, len(names))
+	for _, name := range names {
+		file := byFile[name]
+		sort.SliceStable(file.functions, func(i, j int) bool {
+			return file.functions[i].Line < file.functions[j].Line
+		})
+		result = append(result, *file)
+	}
+	return result
+}
+
+// packageName returns the package path of a Go symbol, e.g.
+// "github.com/a/b.(*T).M" becomes "github.com/a/b"
+func packageName(symbol string) string {
+	slash := strings.LastIndex(symbol, "/")
+	dot := strings.Index(symbol[slash+1:], ".")
+	if dot < 0 {
+		return symbol
+	}
+	return symbol[:slash+1+dot]
+}
+
+// slugify turns a package path into a file name fragment
+func slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
@@ -17,6 +17,8 @@ func getAIPrompt(profileType parser.ProfileType) string {
 		return goroutineAIPrompt()
 	case parser.TypeMutex:
 		return mutexAIPrompt()
+	case parser.TypeSched, parser.TypeSyscall, parser.TypeNet, parser.TypeSync:
+		return traceAIPrompt()
 	default:
 		return genericAIPrompt()
 	}
@@ -136,6 +138,32 @@ Focus on actionable insights to reduce lock contention and improve concurrent pe
 `
 }
 
+func traceAIPrompt() string {
+	return `
+---
+
+## AI Analysis Request
+
+Please analyze this profile derived from a Go execution trace and provide:
+
+1. **Latency Sources**: Identify the call sites where goroutines spend the most time waiting, and what they wait for.
+
+2. **Runtime Health**:
+   - Is GOMAXPROCS well utilized, or are goroutines starved for processors?
+   - Do GC cycles or stop-the-world pauses contribute noticeably to latency?
+
+3. **Concurrency Patterns**:
+   - Are goroutines blocked on channels, locks or I/O longer than expected?
+   - Are there signs of too many runnable goroutines competing for processors?
+
+4. **Recommendations**:
+   - Which waits should be reduced first?
+   - Would batching, buffering, fewer goroutines or less allocation help?
+
+Focus on actionable insights to reduce goroutine latency.
+`
+}
+
 func genericAIPrompt() string {
 	return `
 ---
@@ -215,3 +243,51 @@ func FormatNumber(n int64) string {
 	}
 	return fmt.Sprintf("%.1fG", float64(n)/1_000_000_000)
 }
+
+// FormatValue formats a metric value in the unit of the given profile type
+func FormatValue(profileType parser.ProfileType, value int64) string {
+	return FormatUnit(valueUnit(profileType), value)
+}
+
+// FormatUnit formats a value according to its pprof unit (nanoseconds, bytes or count)
+func FormatUnit(unit string, value int64) string {
+	switch unit {
+	case "nanoseconds":
+		return FormatDuration(value)
+	case "bytes":
+		return FormatBytes(value)
+	default:
+		return FormatNumber(value)
+	}
+}
+
+// valueUnit returns the unit of the primary metric of a profile type
+func valueUnit(profileType parser.ProfileType) string {
+	switch profileType {
+	case parser.TypeCPU, parser.TypeMutex, parser.TypeSched, parser.TypeSyscall, parser.TypeNet, parser.TypeSync:
+		return "nanoseconds"
+	case parser.TypeHeap:
+		return "bytes"
+	default:
+		return "count"
+	}
+}
+
+// metricUnit returns the unit of a profile's primary metric
+func metricUnit(p *parser.Profile) string {
+	if p.Metric.Unit != "" {
+		return p.Metric.Unit
+	}
+	return valueUnit(p.Type)
+}
+
+// FormatLocation formats a source location as file:line, or "-" when unknown
+func FormatLocation(file string, line int) string {
+	if file == "" {
+		return "-"
+	}
+	if line <= 0 {
+		return file
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"sort"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// DefaultNodeFraction is the default minimum weight of a call tree node as
+// a fraction of the profile total, matching pprof's -nodefraction
+const DefaultNodeFraction = 0.005
+
+// TreeRow is one node of a call tree, flattened in depth-first order
+type TreeRow struct {
+	Depth  int
+	Name   string
+	Weight int64   // Weight of the samples passing through this node
+	Pct    float64 // Weight as a percentage of the profile total
+}
+
+// treeNode is a call tree node under construction
+type treeNode struct {
+	name     string
+	weight   int64
+	children map[string]*treeNode
+}
+
+// add records a stack below the node, descending into children
+func (n *treeNode) add(stack []string, weight int64) {
+	for _, frame := range stack {
+		child, ok := n.children[frame]
+		if !ok {
+			child = &treeNode{name: frame, children: make(map[string]*treeNode)}
+			n.children[frame] = child
+		}
+		child.weight += weight
+		n = child
+	}
+}
+
+// flatten appends the node's children to rows, heaviest first, skipping
+// nodes lighter than minWeight
+func (n *treeNode) flatten(rows []TreeRow, depth int, minWeight int64, total int64, name func(string) string) []TreeRow {
+	children := make([]*treeNode, 0, len(n.children))
+	for _, child := range n.children {
+		if child.weight >= minWeight {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].weight != children[j].weight {
+			return children[i].weight > children[j].weight
+		}
+		return children[i].name < children[j].name
+	})
+
+	for _, child := range children {
+		row := TreeRow{Depth: depth, Name: name(child.name), Weight: child.weight}
+		if total > 0 {
+			row.Pct = float64(child.weight) / float64(total) * 100
+		}
+		rows = append(rows, row)
+		rows = child.flatten(rows, depth+1, minWeight, total, name)
+	}
+	return rows
+}
+
+// newTreeNode returns an empty tree root
+func newTreeNode() *treeNode {
+	return &treeNode{children: make(map[string]*treeNode)}
+}
+
+// minTreeWeight returns the lightest node weight kept by the node fraction
+func (g *Generator) minTreeWeight() int64 {
+	return max(int64(float64(g.profile.TotalSamples)*g.nodeFraction), 1)
+}
+
+// treeFrameName returns how a frame is labelled in trees
+func (g *Generator) treeFrameName(frame string) string {
+	if g.compaction.ShortenPaths {
+		return shortenFrame(frame)
+	}
+	return frame
+}
+
+// callTree builds the top-down call tree from the profile's samples
+func (g *Generator) callTree() []TreeRow {
+	root := newTreeNode()
+	for _, s := range g.profile.Samples {
+		root.add(s.Stack, s.Value)
+	}
+	return root.flatten(nil, 0, g.minTreeWeight(), g.profile.TotalSamples, g.treeFrameName)
+}
+
+// callerTree builds the inverted tree of fn's callers: fn is the root and
+// each level below it is one frame further from the leaf
+func (g *Generator) callerTree(fn parser.Function) []TreeRow {
+	root := newTreeNode()
+	for _, s := range g.profile.Samples {
+		// Use the innermost occurrence so recursion is counted once
+		i := lastIndex(s.Stack, fn.Name)
+		if i < 0 {
+			continue
+		}
+		callers := make([]string, 0, i+1)
+		for j := i; j >= 0; j-- {
+			callers = append(callers, s.Stack[j])
+		}
+		root.add(callers, s.Value)
+	}
+	return root.flatten(nil, 0, g.minTreeWeight(), g.profile.TotalSamples, g.treeFrameName)
+}
+
+// lastIndex returns the index of the last occurrence of frame in stack
+func lastIndex(stack []string, frame string) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == frame {
+			return i
+		}
+	}
+	return -1
+}
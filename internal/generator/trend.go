@@ -0,0 +1,501 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// sparkLevels are the block characters used to draw text sparklines
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// TrendPoint is a single profile snapshot in a time series
+type TrendPoint struct {
+	Label   string // Snapshot label, usually the file name
+	Profile *parser.Profile
+}
+
+// TrendGenerator generates markdown showing how profiles evolve over time
+type TrendGenerator struct {
+	points []TrendPoint
+	topN   int
+}
+
+// NewTrendGenerator creates a new trend generator.
+// Points are ordered by collection time when every profile has a timestamp,
+// and by label otherwise.
+func NewTrendGenerator(points []TrendPoint, opts ...TrendOption) *TrendGenerator {
+	g := &TrendGenerator{
+		points: OrderTrendPoints(points),
+		topN:   20,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// TrendOption configures a TrendGenerator
+type TrendOption func(*TrendGenerator)
+
+// WithTrendTopN sets the number of top functions to display
+func WithTrendTopN(n int) TrendOption {
+	return func(g *TrendGenerator) {
+		g.topN = n
+	}
+}
+
+// OrderTrendPoints returns a copy of points ordered by profile timestamp,
+// falling back to label order when any profile lacks a timestamp
+func OrderTrendPoints(points []TrendPoint) []TrendPoint {
+	ordered := make([]TrendPoint, len(points))
+	copy(ordered, points)
+
+	byTime := true
+	for _, p := range ordered {
+		if p.Profile.Time.IsZero() {
+			byTime = false
+			break
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if byTime {
+			return ordered[i].Profile.Time.Before(ordered[j].Profile.Time)
+		}
+		return ordered[i].Label < ordered[j].Label
+	})
+	return ordered
+}
+
+// Series is a metric sampled across a sequence of profiles
+type Series struct {
+	Name   string
+	Unit   string // pprof unit: nanoseconds, bytes or count
+	Values []int64
+}
+
+// First returns the first value of the series
+func (s Series) First() int64 {
+	if len(s.Values) == 0 {
+		return 0
+	}
+	return s.Values[0]
+}
+
+// Last returns the last value of the series
+func (s Series) Last() int64 {
+	if len(s.Values) == 0 {
+		return 0
+	}
+	return s.Values[len(s.Values)-1]
+}
+
+// Min returns the smallest value of the series
+func (s Series) Min() int64 {
+	var m int64
+	for i, v := range s.Values {
+		if i == 0 || v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// Max returns the largest value of the series
+func (s Series) Max() int64 {
+	var m int64
+	for i, v := range s.Values {
+		if i == 0 || v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+// Delta returns the change between the last and first values
+func (s Series) Delta() int64 {
+	return s.Last() - s.First()
+}
+
+// DeltaPct returns the change relative to the first value
+func (s Series) DeltaPct() float64 {
+	if s.First() != 0 {
+		return float64(s.Delta()) / float64(s.First()) * 100
+	}
+	if s.Last() != 0 {
+		return 100.0
+	}
+	return 0
+}
+
+// Increasing reports whether the series never decreases and ends higher
+// than it started
+func (s Series) Increasing() bool {
+	if len(s.Values) < 2 || s.Last() <= s.First() {
+		return false
+	}
+	for i := 1; i < len(s.Values); i++ {
+		if s.Values[i] < s.Values[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// SteadilyGrowing reports whether the series is increasing over at least
+// three points
+func (s Series) SteadilyGrowing() bool {
+	return len(s.Values) >= 3 && s.Increasing()
+}
+
+// steadilyShrinking reports whether the series never increases and ends lower
+// than it started over at least three points
+func (s Series) steadilyShrinking() bool {
+	if len(s.Values) < 3 || s.Last() >= s.First() {
+		return false
+	}
+	for i := 1; i < len(s.Values); i++ {
+		if s.Values[i] > s.Values[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// Trend classifies the overall direction of the series
+func (s Series) Trend() string {
+	switch {
+	case len(s.Values) < 2:
+		return "-"
+	case s.SteadilyGrowing():
+		return "steadily growing"
+	case s.steadilyShrinking():
+		return "steadily shrinking"
+	case s.Last() > s.First():
+		return "growing"
+	case s.Last() < s.First():
+		return "shrinking"
+	default:
+		return "stable"
+	}
+}
+
+// Sparkline renders the series as a text sparkline
+func (s Series) Sparkline() string {
+	return Sparkline(s.Values)
+}
+
+// Sparkline renders values as a row of block characters scaled between
+// the minimum and maximum value
+func Sparkline(values []int64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	s := Series{Values: values}
+	lo, hi := s.Min(), s.Max()
+
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = int(float64(v-lo) / float64(hi-lo) * float64(len(sparkLevels)-1))
+		}
+		sb.WriteRune(sparkLevels[level])
+	}
+	return sb.String()
+}
+
+// FunctionTrend is the flat value of a single function across snapshots
+type FunctionTrend struct {
+	Series
+	File string
+	Line int
+}
+
+// Snapshot describes one profile in the trend report
+type Snapshot struct {
+	Label   string
+	Time    time.Time
+	Elapsed time.Duration
+	Total   int64
+}
+
+// Generate generates trend markdown
+func (g *TrendGenerator) Generate() (string, error) {
+	if len(g.points) == 0 {
+		return "", fmt.Errorf("no profiles to compare")
+	}
+
+	tmpl, err := g.getTemplate()
+	if err != nil {
+		return "", fmt.Errorf("failed to get template: %w", err)
+	}
+
+	data := g.prepareTemplateData()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// prepareTemplateData prepares data for template rendering
+func (g *TrendGenerator) prepareTemplateData() map[string]interface{} {
+	profileType := g.points[0].Profile.Type
+
+	functions := g.computeFunctionTrends()
+
+	// Rank by peak value so functions that spiked in the middle still show up
+	top := make([]FunctionTrend, len(functions))
+	copy(top, functions)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Max() != top[j].Max() {
+			return top[i].Max() > top[j].Max()
+		}
+		return top[i].Name < top[j].Name
+	})
+	if len(top) > g.topN {
+		top = top[:g.topN]
+	}
+
+	var growing []FunctionTrend
+	for _, fn := range functions {
+		if fn.SteadilyGrowing() {
+			growing = append(growing, fn)
+		}
+	}
+	sort.Slice(growing, func(i, j int) bool {
+		if growing[i].Delta() != growing[j].Delta() {
+			return growing[i].Delta() > growing[j].Delta()
+		}
+		return growing[i].Name < growing[j].Name
+	})
+	if len(growing) > g.topN {
+		growing = growing[:g.topN]
+	}
+
+	data := map[string]interface{}{
+		"Type":      string(profileType),
+		"Unit":      metricUnit(g.points[0].Profile),
+		"Snapshots": g.snapshots(),
+		"Totals":    g.computeTotals(),
+		"Functions": top,
+		"Growing":   growing,
+	}
+
+	if profileType == parser.TypeHeap {
+		leaks, ok := g.computeHeapLeaks()
+		data["HeapLeaks"] = leaks
+		data["HasInUse"] = ok
+	}
+	if profileType == parser.TypeGoroutine {
+		leaks, sites := detectGoroutineLeaks(g.profiles(), g.topN)
+		data["GoroutineLeaks"] = leaks
+		data["CreationSites"] = sites
+	}
+	_, rateUnit := timeAxis(g.profiles())
+	data["RateUnit"] = rateUnit
+
+	return data
+}
+
+// snapshots describes each profile in order
+func (g *TrendGenerator) snapshots() []Snapshot {
+	first := g.points[0].Profile.Time
+	snapshots := make([]Snapshot, 0, len(g.points))
+	for _, p := range g.points {
+		s := Snapshot{
+			Label: p.Label,
+			Time:  p.Profile.Time,
+			Total: p.Profile.TotalSamples,
+		}
+		if !first.IsZero() && !p.Profile.Time.IsZero() {
+			s.Elapsed = p.Profile.Time.Sub(first)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots
+}
+
+// computeTotals builds the summary series for the profile type
+func (g *TrendGenerator) computeTotals() []Series {
+	series := func(name, unit string, value func(*parser.Profile) int64) Series {
+		s := Series{Name: name, Unit: unit}
+		for _, p := range g.points {
+			s.Values = append(s.Values, value(p.Profile))
+		}
+		return s
+	}
+
+	switch g.points[0].Profile.Type {
+	case parser.TypeCPU:
+		return []Series{
+			series("Total CPU Time", "nanoseconds", func(p *parser.Profile) int64 { return p.TotalSamples }),
+		}
+	case parser.TypeHeap:
+		return []Series{
+			series("Allocated Bytes", "bytes", func(p *parser.Profile) int64 { return p.Stats.AllocBytes }),
+			series("Allocated Objects", "count", func(p *parser.Profile) int64 { return p.Stats.AllocObjects }),
+			series("In-Use Bytes", "bytes", func(p *parser.Profile) int64 { return p.Stats.InUseBytes }),
+			series("In-Use Objects", "count", func(p *parser.Profile) int64 { return p.Stats.InUseObjects }),
+		}
+	case parser.TypeGoroutine:
+		return []Series{
+			series("Total Goroutines", "count", func(p *parser.Profile) int64 { return p.Stats.TotalGoroutines }),
+		}
+	case parser.TypeMutex:
+		return []Series{
+			series("Contention Time", "nanoseconds", func(p *parser.Profile) int64 { return p.Stats.TotalContentionTime }),
+			series("Total Waits", "count", func(p *parser.Profile) int64 { return p.Stats.TotalWaits }),
+		}
+	default:
+		return []Series{
+			series("Total Samples", "count", func(p *parser.Profile) int64 { return p.TotalSamples }),
+		}
+	}
+}
+
+// computeFunctionTrends builds the flat value series of every function
+func (g *TrendGenerator) computeFunctionTrends() []FunctionTrend {
+	unit := metricUnit(g.points[0].Profile)
+	trends := make(map[string]*FunctionTrend)
+	var names []string
+
+	for i, p := range g.points {
+		for _, fn := range p.Profile.Functions {
+			t, ok := trends[fn.Name]
+			if !ok {
+				t = &FunctionTrend{
+					Series: Series{Name: fn.Name, Unit: unit, Values: make([]int64, len(g.points))},
+					File:   fn.File,
+					Line:   fn.Line,
+				}
+				trends[fn.Name] = t
+				names = append(names, fn.Name)
+			}
+			t.Values[i] += fn.Flat
+		}
+	}
+
+	result := make([]FunctionTrend, 0, len(names))
+	for _, name := range names {
+		result = append(result, *trends[name])
+	}
+	return result
+}
+
+// getTemplate returns the trend template
+func (g *TrendGenerator) getTemplate() (*template.Template, error) {
+	tmpl := `# {{ .Type }} Profile Trend
+
+## Snapshots
+
+| # | Snapshot | Collected | Elapsed | Total |
+|---|----------|-----------|---------|-------|
+{{- range $i, $s := .Snapshots }}
+| {{ add $i 1 }} | ` + "`" + `{{ $s.Label }}` + "`" + ` | {{ if $s.Time.IsZero }}-{{ else }}{{ $s.Time.Format "2006-01-02 15:04:05" }}{{ end }} | {{ if $s.Time.IsZero }}-{{ else }}{{ formatDuration $s.Elapsed.Nanoseconds }}{{ end }} | {{ formatUnit $.Unit $s.Total }} |
+{{- end }}
+
+## Totals Over Time
+
+| Metric | Trend | First | Last | Min | Max | Δ | Δ% | Direction |
+|--------|-------|-------|------|-----|-----|---|----|-----------|
+{{- range .Totals }}
+| {{ .Name }} | ` + "`" + `{{ .Sparkline }}` + "`" + ` | {{ formatUnit .Unit .First }} | {{ formatUnit .Unit .Last }} | {{ formatUnit .Unit .Min }} | {{ formatUnit .Unit .Max }} | {{ formatUnitDelta .Unit .Delta }} | {{ printf "%+.1f" .DeltaPct }}% | {{ .Trend }} |
+{{- end }}
+
+## Top Functions Over Time
+
+| Rank | Function | Location | Trend | First | Last | Max | Δ | Δ% | Direction |
+|------|----------|----------|-------|-------|------|-----|---|----|-----------|
+{{- range $i, $fn := .Functions }}
+| {{ add $i 1 }} | ` + "`" + `{{ $fn.Name }}` + "`" + ` | {{ formatLocation $fn.File $fn.Line }} | ` + "`" + `{{ $fn.Sparkline }}` + "`" + ` | {{ formatUnit $fn.Unit $fn.First }} | {{ formatUnit $fn.Unit $fn.Last }} | {{ formatUnit $fn.Unit $fn.Max }} | {{ formatUnitDelta $fn.Unit $fn.Delta }} | {{ printf "%+.1f" $fn.DeltaPct }}% | {{ $fn.Trend }} |
+{{- end }}
+
+## Steadily Growing Functions
+{{ if .Growing }}
+Functions whose flat value never decreased between snapshots and ended higher than it started.
+
+| Rank | Function | Location | Trend | First | Last | Δ | Δ% |
+|------|----------|----------|-------|-------|------|---|----|
+{{- range $i, $fn := .Growing }}
+| {{ add $i 1 }} | ` + "`" + `{{ $fn.Name }}` + "`" + ` | {{ formatLocation $fn.File $fn.Line }} | ` + "`" + `{{ $fn.Sparkline }}` + "`" + ` | {{ formatUnit $fn.Unit $fn.First }} | {{ formatUnit $fn.Unit $fn.Last }} | {{ formatUnitDelta $fn.Unit $fn.Delta }} | {{ printf "%+.1f" $fn.DeltaPct }}% |
+{{- end }}
+{{- else }}
+_No function grew steadily across {{ len .Snapshots }} snapshots (at least 3 are required)._
+{{- end }}
+{{- if eq .Type "heap" }}
+
+## Suspected Leaks
+{{ if not .HasInUse }}
+_The profiles carry no in-use data (inuse_space), so leaks cannot be detected._
+{{- else if .HeapLeaks }}
+Allocation sites whose in-use memory never decreased between snapshots and ended higher than it started, ranked by growth rate.
+
+| Rank | Allocation Site | Location | In-Use Bytes | In-Use Objects | Growth/{{ .RateUnit }} | Objects/{{ .RateUnit }} | Trend |
+|------|-----------------|----------|--------------|----------------|-------------|--------------|-------|
+{{- range $i, $l := .HeapLeaks }}
+| {{ add $i 1 }} | ` + "`" + `{{ $l.Name }}` + "`" + ` | {{ formatLocation $l.File $l.Line }} | {{ formatUnit "bytes" $l.Bytes.First }} → {{ formatUnit "bytes" $l.Bytes.Last }} | {{ formatUnit "count" $l.Objects.First }} → {{ formatUnit "count" $l.Objects.Last }} | {{ formatUnitDelta "bytes" $l.BytesRate }} | {{ formatUnitDelta "count" $l.ObjectsRate }} | ` + "`" + `{{ $l.Bytes.Sparkline }}` + "`" + ` |
+{{- end }}
+{{- range $i, $l := .HeapLeaks }}
+
+### {{ add $i 1 }}. {{ $l.Name }}
+
+` + "```" + `
+{{ formatStack $l.Stack }}
+` + "```" + `
+{{- end }}
+{{- else }}
+_No allocation site grew in every snapshot._
+{{- end }}
+{{- end }}
+{{- if eq .Type "goroutine" }}
+
+## Suspected Goroutine Leaks
+{{ template "goroutine-leaks" . }}
+{{- end }}
+
+---
+
+## AI Analysis Request
+
+Please analyze this pprof trend and provide:
+
+1. **Overall Direction**: Are the totals stable, growing, or shrinking over the snapshots? Is the growth linear, accelerating, or levelling off?
+
+2. **Growth Sources**:
+   - Which functions account for most of the growth?
+   - Do the steadily growing functions point to unbounded caches, queues, or leaked resources?
+
+3. **Correlation**:
+   - Do changes in individual functions line up with changes in the totals?
+   - Are there spikes that appear in one snapshot and then disappear?
+
+4. **Recommendations**:
+   - Which code paths should be investigated first?
+   - What additional profiles or metrics would confirm the diagnosis?
+
+Focus on actionable insights to explain how the profile evolves over time.
+`
+
+	funcs := template.FuncMap{
+		"add":             func(a, b int) int { return a + b },
+		"formatDuration":  FormatDuration,
+		"formatUnit":      FormatUnit,
+		"formatUnitDelta": FormatUnitDelta,
+		"formatLocation":  FormatLocation,
+		"formatStack":     formatStack,
+	}
+
+	return template.New("trend").Funcs(funcs).Parse(tmpl + goroutineLeaksTemplate)
+}
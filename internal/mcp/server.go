@@ -0,0 +1,176 @@
+// Package mcp serves go-pprof-md reports to AI assistants over the Model
+// Context Protocol, using the stdio transport: newline-delimited JSON-RPC
+// 2.0 messages on stdin and stdout.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// protocolVersions are the MCP revisions the server speaks, newest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// request is a JSON-RPC request, or a notification if ID is absent
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server answers MCP requests with the go-pprof-md tools
+type Server struct {
+	version string
+	tools   []tool
+}
+
+// NewServer creates a server reporting the given version to clients
+func NewServer(version string) *Server {
+	return &Server{version: version, tools: tools()}
+}
+
+// Serve reads requests from r and writes responses to w until r is
+// exhausted or ctx is done. Requests are handled one at a time.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+
+	for ctx.Err() == nil {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// The stream cannot be resynchronized after invalid JSON
+			enc.Encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}})
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+
+		resp := s.handle(raw)
+		if resp == nil {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// handle answers one message, returning nil for notifications
+func (s *Server) handle(raw json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeInvalidRequest, "invalid request"}}
+	}
+	if req.ID == nil {
+		// Notifications, such as notifications/initialized, need no answer
+		return nil
+	}
+
+	result, rpcErr := s.call(req)
+	resp := &response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+	if rpcErr == nil && result == nil {
+		resp.Result = struct{}{}
+	}
+	return resp
+}
+
+// call dispatches a request to its method
+func (s *Server) call(req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "go-pprof-md", "version": s.version},
+			"instructions":    instructions,
+		}, nil
+
+	case "ping":
+		return nil, nil
+
+	case "tools/list":
+		list := make([]map[string]any, 0, len(s.tools))
+		for _, t := range s.tools {
+			list = append(list, map[string]any{
+				"name":        t.name,
+				"description": t.description,
+				"inputSchema": json.RawMessage(t.schema),
+			})
+		}
+		return map[string]any{"tools": list}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		idx := slices.IndexFunc(s.tools, func(t tool) bool { return t.name == params.Name })
+		if idx < 0 {
+			return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool: %s", params.Name)}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+
+		// Tool failures are results, so the assistant can see and fix them
+		text, err := s.tools[idx].run(params.Arguments)
+		isError := err != nil
+		if isError {
+			text = err.Error()
+		}
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": text}},
+			"isError": isError,
+		}, nil
+
+	default:
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
+
+// instructions tells clients how to use the tools
+const instructions = `Tools to analyze Go pprof profiles, goroutine dumps, perf script output and execution traces on the local filesystem. ` +
+	`Start with list_top for the hottest functions, then use get_call_paths and get_source to dig into one function. ` +
+	`show_profile returns the full markdown report, diff_profiles compares two profiles of the same type.`
@@ -0,0 +1,245 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// session sends requests to a server and returns its responses by id
+func session(t *testing.T, requests ...string) map[string]response {
+	t.Helper()
+	var out bytes.Buffer
+	if err := NewServer("test").Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	responses := make(map[string]response)
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp struct {
+			response
+			Result json.RawMessage `json:"result"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		resp.response.Result = resp.Result
+		responses[string(resp.ID)] = resp.response
+	}
+	return responses
+}
+
+// toolText returns the text and error flag of a tools/call response
+func toolText(t *testing.T, resp response) (string, bool) {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil || len(result.Content) != 1 {
+		t.Fatalf("invalid tool result %s: %v", resp.Result, err)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+// callTool returns a tools/call request
+func callTool(id int, name string, args map[string]any) string {
+	req, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	return string(req)
+}
+
+// TestServerProtocol tests initialization, tool listing and errors
+func TestServerProtocol(t *testing.T) {
+	responses := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"bogus"}}`,
+		`{"id":6,"method":"ping"}`,
+	)
+	if len(responses) != 6 {
+		t.Fatalf("got %d responses, want 6 (none for the notification)", len(responses))
+	}
+
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	if err := json.Unmarshal(responses["1"].Result.(json.RawMessage), &init); err != nil {
+		t.Fatalf("invalid initialize result: %v", err)
+	}
+	if init.ProtocolVersion != "2024-11-05" || init.ServerInfo.Name != "go-pprof-md" {
+		t.Errorf("unexpected initialize result: %+v", init)
+	}
+
+	var list struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(responses["2"].Result.(json.RawMessage), &list); err != nil {
+		t.Fatalf("invalid tools/list result: %v", err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		var schema map[string]any
+		if err := json.Unmarshal(tool.InputSchema, &schema); err != nil || schema["type"] != "object" {
+			t.Errorf("%s: invalid input schema: %v", tool.Name, err)
+		}
+	}
+	if got := strings.Join(names, ","); got != "show_profile,diff_profiles,list_top,get_call_paths,get_source" {
+		t.Errorf("tools = %s", got)
+	}
+
+	if string(responses["3"].Result.(json.RawMessage)) != "{}" {
+		t.Errorf("ping result = %s, want {}", responses["3"].Result)
+	}
+	for id, code := range map[string]int{"4": codeMethodNotFound, "5": codeInvalidParams, "null": codeInvalidRequest} {
+		if err := responses[id].Error; err == nil || err.Code != code {
+			t.Errorf("response %s error = %+v, want code %d", id, err, code)
+		}
+	}
+}
+
+// TestServerTools tests each tool against a profile of a temporary source
+// file
+func TestServerTools(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(source, []byte("package main\n\nfunc work() {\n\tfor {\n\t}\n}\n\nfunc main() {\n\twork()\n}\n"), 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	// writeProfile writes a CPU profile of main.main calling main.work,
+	// recording file as their source
+	writeProfile := func(name, file string, value int64) string {
+		work := &profile.Function{ID: 1, Name: "main.work", Filename: file}
+		mainFn := &profile.Function{ID: 2, Name: "main.main", Filename: file}
+		workLoc := &profile.Location{ID: 1, Line: []profile.Line{{Function: work, Line: 4}}}
+		mainLoc := &profile.Location{ID: 2, Line: []profile.Line{{Function: mainFn, Line: 9}}}
+		// Line 5 is hotter than line 4, which is sampled first
+		hotLoc := &profile.Location{ID: 3, Line: []profile.Line{{Function: work, Line: 5}}}
+		prof := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+			PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+			Period:     10000000,
+			Sample: []*profile.Sample{
+				{Location: []*profile.Location{workLoc, mainLoc}, Value: []int64{value / 40000000, value / 4}},
+				{Location: []*profile.Location{hotLoc, mainLoc}, Value: []int64{value * 3 / 40000000, value * 3 / 4}},
+			},
+			Location: []*profile.Location{workLoc, mainLoc, hotLoc},
+			Function: []*profile.Function{work, mainFn},
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("failed to create profile: %v", err)
+		}
+		defer f.Close()
+		if err := prof.Write(f); err != nil {
+			t.Fatalf("failed to write profile: %v", err)
+		}
+		return path
+	}
+	base := writeProfile("base.prof", source, 100000000)
+	newer := writeProfile("new.prof", source, 300000000)
+
+	tests := []struct {
+		name      string
+		tool      string
+		args      map[string]any
+		wantError bool
+		expected  []string
+	}{
+		{
+			name:     "show_profile",
+			tool:     "show_profile",
+			args:     map[string]any{"path": base},
+			expected: []string{"# cpu Profile Analysis", "`main.work`"},
+		},
+		{
+			name:     "show_profile json",
+			tool:     "show_profile",
+			args:     map[string]any{"path": base, "format": "json"},
+			expected: []string{`"version": 1`, `"name": "main.work"`},
+		},
+		{
+			name:     "diff_profiles",
+			tool:     "diff_profiles",
+			args:     map[string]any{"base": base, "new": newer},
+			expected: []string{"# cpu Profile Diff: Base vs New", "main.work"},
+		},
+		{
+			name:     "list_top",
+			tool:     "list_top",
+			args:     map[string]any{"path": base, "top": 1},
+			expected: []string{"cpu profile, cpu: total 100.00ms", "| 1 | `main.work` | " + source + ":4 | 100.00ms | 100.00% | 100.00ms | 100.00% |"},
+		},
+		{
+			name:     "get_call_paths",
+			tool:     "get_call_paths",
+			args:     map[string]any{"path": base, "function": "main.work"},
+			expected: []string{"## main.work", "1. 100.00ms (100.00%): main.main → main.work", "### Callers\n\n- `main.main`: 100.00ms (100.00%)"},
+		},
+		{
+			name:     "get_source",
+			tool:     "get_source",
+			args:     map[string]any{"path": base, "function": "work", "context": 1},
+			expected: []string{"## main.work", ":5 (flat 100.00ms", "```go\n•     4  \tfor {  // flat 25.00ms, cum 25.00ms\n→     5  \t}  // flat 75.00ms, cum 75.00ms\n      6  }\n```"},
+		},
+		{
+			name:     "get_source from source_dir",
+			tool:     "get_source",
+			args:     map[string]any{"path": writeProfile("moved.prof", "/build/app/main.go", 10000000), "function": "main.main", "source_dir": dir},
+			expected: []string{"→     9  \twork()"},
+		},
+		{name: "missing path", tool: "list_top", args: map[string]any{}, wantError: true, expected: []string{"path is required"}},
+		{name: "unknown argument", tool: "list_top", args: map[string]any{"path": base, "tpo": 3}, wantError: true, expected: []string{`unknown field "tpo"`}},
+		{name: "unknown function", tool: "get_call_paths", args: map[string]any{"path": base, "function": "nothing"}, wantError: true, expected: []string{`no function matching "nothing"`}},
+	}
+
+	var requests []string
+	for i, tt := range tests {
+		requests = append(requests, callTool(i, tt.tool, tt.args))
+	}
+	responses := session(t, requests...)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := toolText(t, responses[fmt.Sprint(i)])
+			if isError != tt.wantError {
+				t.Errorf("isError = %v, want %v: %s", isError, tt.wantError, text)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(text, expected) {
+					t.Errorf("result missing expected string %q:\n%s", expected, text)
+				}
+			}
+		})
+	}
+}
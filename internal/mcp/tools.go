@@ -0,0 +1,383 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/alingse/go-pprof-md/internal/generator"
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// maxMatches is the number of functions get_call_paths and get_source
+// describe when several match the requested name
+const maxMatches = 5
+
+// tool is an MCP tool: its schema describes the JSON arguments of run
+type tool struct {
+	name        string
+	description string
+	schema      string
+	run         func(args json.RawMessage) (string, error)
+}
+
+// profileSchema is the schema of the arguments selecting and filtering a
+// profile, shared by the single-profile tools
+const profileSchema = `
+		"path": {"type": "string", "description": "Profile file: pprof profile, goroutine dump, perf script output, folded stacks or execution trace"},
+		"type": {"type": "string", "description": "Profile type: cpu, heap, goroutine, mutex, generic, or sched, syscall, net, sync for traces (default: auto-detect)"},
+		"sample_index": {"type": "string", "description": "Sample type to report, e.g. inuse_space or alloc_objects"},
+		"focus": {"type": "string", "description": "Only count samples with a function matching this regexp"},
+		"ignore": {"type": "string", "description": "Drop samples with a function matching this regexp"}`
+
+// tools returns the tools served over MCP
+func tools() []tool {
+	return []tool{
+		{
+			name:        "show_profile",
+			description: "Full analysis report of a profile: summary statistics, top functions and their call paths.",
+			schema: `{"type": "object", "properties": {` + profileSchema + `,
+		"top": {"type": "integer", "description": "Number of top functions (default 20)"},
+		"max_tokens": {"type": "integer", "description": "Trim the report to about this many tokens"},
+		"format": {"type": "string", "enum": ["markdown", "json"], "description": "Report format (default markdown)"}
+	}, "required": ["path"]}`,
+			run: showProfile,
+		},
+		{
+			name:        "diff_profiles",
+			description: "Compare two profiles of the same type and report the functions that changed most.",
+			schema: `{"type": "object", "properties": {
+		"base": {"type": "string", "description": "Base profile file"},
+		"new": {"type": "string", "description": "New profile file"},
+		"type": {"type": "string", "description": "Profile type of both files (default: auto-detect)"},
+		"top": {"type": "integer", "description": "Number of changed functions (default 20)"}
+	}, "required": ["base", "new"]}`,
+			run: diffProfiles,
+		},
+		{
+			name:        "list_top",
+			description: "Table of the top functions of a profile by flat value, with cumulative values and source locations.",
+			schema: `{"type": "object", "properties": {` + profileSchema + `,
+		"top": {"type": "integer", "description": "Number of functions (default 20)"}
+	}, "required": ["path"]}`,
+			run: listTop,
+		},
+		{
+			name:        "get_call_paths",
+			description: "Call paths leading to a function, heaviest first, with its immediate callers and callees.",
+			schema: `{"type": "object", "properties": {` + profileSchema + `,
+		"function": {"type": "string", "description": "Function name, e.g. main.(*Server).handle; a substring matches every function containing it"},
+		"max_paths": {"type": "integer", "description": "Maximum call paths (default 10)"}
+	}, "required": ["path", "function"]}`,
+			run: getCallPaths,
+		},
+		{
+			name:        "get_source",
+			description: "Source code around a function's hottest sampled line, with the value of each sampled line, read from the local filesystem.",
+			schema: `{"type": "object", "properties": {` + profileSchema + `,
+		"function": {"type": "string", "description": "Function name; a substring matches every function containing it"},
+		"context": {"type": "integer", "description": "Lines of source before and after the hottest line (default 10)"},
+		"source_dir": {"type": "string", "description": "Directory to find the source in when the profile's paths are from another machine"}
+	}, "required": ["path", "function"]}`,
+			run: getSource,
+		},
+	}
+}
+
+// profileArgs are the arguments selecting and filtering a profile
+type profileArgs struct {
+	Path        string `json:"path"`
+	Type        string `json:"type"`
+	SampleIndex string `json:"sample_index"`
+	Focus       string `json:"focus"`
+	Ignore      string `json:"ignore"`
+}
+
+// load parses and filters the profile
+func (a profileArgs) load() (*parser.Profile, error) {
+	profile, err := parseFile(a.Path, a.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var focus, ignore *regexp.Regexp
+	if a.Focus != "" {
+		if focus, err = regexp.Compile(a.Focus); err != nil {
+			return nil, fmt.Errorf("invalid focus: %w", err)
+		}
+	}
+	if a.Ignore != "" {
+		if ignore, err = regexp.Compile(a.Ignore); err != nil {
+			return nil, fmt.Errorf("invalid ignore: %w", err)
+		}
+	}
+	return profile.Filter(focus, ignore)
+}
+
+// report returns the report of the profile's top n functions
+func (a profileArgs) report(n int, opts ...generator.Option) (generator.JSONReport, error) {
+	profile, err := a.load()
+	if err != nil {
+		return generator.JSONReport{}, err
+	}
+	opts = append(opts, generator.WithTopN(n), generator.WithSampleIndex(a.SampleIndex))
+	return generator.NewGenerator(profile, opts...).Report()
+}
+
+// parseFile parses a profile file of the given type, or detects it
+func parseFile(path, profileType string) (*parser.Profile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	return parser.ParseData(data, parser.ProfileType(profileType))
+}
+
+// decodeArgs decodes tool arguments, rejecting unknown ones so typos are
+// reported rather than ignored
+func decodeArgs(raw json.RawMessage, args any) error {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// orDefault returns n, or def if n is not positive
+func orDefault(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+// showProfile renders the full report
+func showProfile(raw json.RawMessage) (string, error) {
+	var args struct {
+		profileArgs
+		Top       int    `json:"top"`
+		MaxTokens int    `json:"max_tokens"`
+		Format    string `json:"format"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	profile, err := args.load()
+	if err != nil {
+		return "", err
+	}
+
+	gen := generator.NewGenerator(profile,
+		generator.WithTopN(orDefault(args.Top, 20)),
+		generator.WithAIPrompt(false),
+		generator.WithMaxTokens(args.MaxTokens),
+		generator.WithSampleIndex(args.SampleIndex),
+	)
+	switch args.Format {
+	case "", "markdown":
+		return gen.Generate()
+	case "json":
+		return gen.GenerateJSON()
+	default:
+		return "", fmt.Errorf("invalid format: %s (valid: markdown, json)", args.Format)
+	}
+}
+
+// diffProfiles renders the diff report of two profiles
+func diffProfiles(raw json.RawMessage) (string, error) {
+	var args struct {
+		Base string `json:"base"`
+		New  string `json:"new"`
+		Type string `json:"type"`
+		Top  int    `json:"top"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	base, err := parseFile(args.Base, args.Type)
+	if err != nil {
+		return "", fmt.Errorf("base profile: %w", err)
+	}
+	newProfile, err := parseFile(args.New, args.Type)
+	if err != nil {
+		return "", fmt.Errorf("new profile: %w", err)
+	}
+	if base.Type != newProfile.Type {
+		return "", fmt.Errorf("profile types do not match: base is %s, new is %s", base.Type, newProfile.Type)
+	}
+
+	return generator.NewDiffGenerator(base, newProfile, generator.WithDiffTopN(orDefault(args.Top, 20))).Generate()
+}
+
+// listTop renders a table of the top functions
+func listTop(raw json.RawMessage) (string, error) {
+	var args struct {
+		profileArgs
+		Top int `json:"top"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	report, err := args.report(orDefault(args.Top, 20))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s profile, %s: total %s\n\n", report.Type, report.Metric, generator.FormatUnit(report.Unit, report.Total))
+	b.WriteString("| Rank | Function | Location | Flat | Flat % | Cumulative | Cumulative % |\n")
+	b.WriteString("|------|----------|----------|------|--------|------------|--------------|\n")
+	for _, fn := range report.Functions {
+		fmt.Fprintf(&b, "| %d | `%s` | %s | %s | %.2f%% | %s | %.2f%% |\n",
+			fn.Rank, fn.Name, generator.FormatLocation(fn.File, fn.Line),
+			generator.FormatUnit(report.Unit, fn.Flat), fn.FlatPct,
+			generator.FormatUnit(report.Unit, fn.Cum), fn.CumPct)
+	}
+	return b.String(), nil
+}
+
+// getCallPaths renders the call paths, callers and callees of the
+// functions matching the requested name
+func getCallPaths(raw json.RawMessage) (string, error) {
+	var args struct {
+		profileArgs
+		Function string `json:"function"`
+		MaxPaths int    `json:"max_paths"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	report, err := args.report(math.MaxInt, generator.WithMaxPaths(orDefault(args.MaxPaths, 10)))
+	if err != nil {
+		return "", err
+	}
+	matches, err := findFunctions(report, args.Function)
+	if err != nil {
+		return "", err
+	}
+
+	pct := func(v int64) float64 {
+		if report.Total == 0 {
+			return 0
+		}
+		return float64(v) / float64(report.Total) * 100
+	}
+	format := func(v int64) string {
+		return fmt.Sprintf("%s (%.2f%%)", generator.FormatUnit(report.Unit, v), pct(v))
+	}
+
+	var b strings.Builder
+	for i, fn := range matches {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", fn.Name)
+		fmt.Fprintf(&b, "- **Location:** %s\n", generator.FormatLocation(fn.File, fn.Line))
+		fmt.Fprintf(&b, "- **Flat:** %s\n", format(fn.Flat))
+		fmt.Fprintf(&b, "- **Cumulative:** %s\n", format(fn.Cum))
+
+		if len(fn.CallPaths) > 0 {
+			b.WriteString("\n### Call Paths\n\n")
+			for j, path := range fn.CallPaths {
+				fmt.Fprintf(&b, "%d. %s: %s\n", j+1, format(path.Weight), strings.Join(path.Stack, " → "))
+			}
+		}
+		for _, edges := range []struct {
+			title string
+			edges []generator.JSONEdge
+		}{{"Callers", fn.Callers}, {"Callees", fn.Callees}} {
+			if len(edges.edges) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n", edges.title)
+			for _, e := range edges.edges {
+				fmt.Fprintf(&b, "- `%s`: %s\n", e.Name, format(e.Weight))
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// getSource renders the source around the location of the functions
+// matching the requested name
+func getSource(raw json.RawMessage) (string, error) {
+	var args struct {
+		profileArgs
+		Function  string `json:"function"`
+		Context   int    `json:"context"`
+		SourceDir string `json:"source_dir"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	report, err := args.report(math.MaxInt)
+	if err != nil {
+		return "", err
+	}
+	matches, err := findFunctions(report, args.Function)
+	if err != nil {
+		return "", err
+	}
+	around := orDefault(args.Context, 10)
+
+	var b strings.Builder
+	for i, fn := range matches {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", fn.Name)
+		if fn.File == "" {
+			b.WriteString("The profile records no source location for this function.\n")
+			continue
+		}
+		listing, err := generator.SourceListing(jsonFunction(fn), report.Unit, args.SourceDir, around)
+		if errors.Is(err, generator.ErrSourceNotFound) {
+			fmt.Fprintf(&b, "Source file %s not found; set source_dir to the checkout it was built from.\n", fn.File)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(listing)
+	}
+	return b.String(), nil
+}
+
+// findFunctions returns the function named name, or the functions whose
+// names contain it, heaviest first. Only maxMatches are returned.
+func findFunctions(report generator.JSONReport, name string) ([]generator.JSONFunction, error) {
+	if name == "" {
+		return nil, fmt.Errorf("function is required")
+	}
+	var matches []generator.JSONFunction
+	for _, fn := range report.Functions {
+		if fn.Name == name {
+			return []generator.JSONFunction{fn}, nil
+		}
+		if strings.Contains(fn.Name, name) {
+			matches = append(matches, fn)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no function matching %q in the profile", name)
+	}
+	return matches[:min(len(matches), maxMatches)], nil
+}
+
+// jsonFunction converts a function of a JSON report back to the parser's
+// form for SourceListing
+func jsonFunction(fn generator.JSONFunction) parser.Function {
+	f := parser.Function{Name: fn.Name, File: fn.File, Line: fn.Line, Flat: fn.Flat, Cum: fn.Cum}
+	for _, lv := range fn.Lines {
+		f.Lines = append(f.Lines, parser.LineValue{Line: lv.Line, Flat: lv.Flat, Cum: lv.Cum})
+	}
+	return f
+}
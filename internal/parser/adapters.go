@@ -11,16 +11,36 @@ import (
 
 // convertProfile converts a pprof profile.Profile to internal parser.Profile
 func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, error) {
+	return convertProfileMetric(prof, profileType, "")
+}
+
+// convertProfileMetric converts a pprof profile using the named sample type
+// as the primary metric, or the profile type's default metric if empty
+func convertProfileMetric(prof *profile.Profile, profileType ProfileType, sampleIndex string) (*Profile, error) {
 	if prof == nil {
 		return nil, fmt.Errorf("nil profile")
 	}
 
+	layout, err := newSampleLayout(prof, profileType, sampleIndex)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &Profile{
 		Type:        profileType,
 		SampleTime:  time.Duration(prof.DurationNanos),
 		TotalSamples: 0,
 		Functions:   []Function{},
 		Stats:       Stats{},
+		Metric:      layout.metricType(prof),
+		source:      prof,
+		sampleIndex: sampleIndex,
+	}
+	if prof.TimeNanos > 0 {
+		result.Time = time.Unix(0, prof.TimeNanos)
+	}
+	for _, st := range prof.SampleType {
+		result.SampleTypes = append(result.SampleTypes, SampleType{Type: st.Type, Unit: st.Unit})
 	}
 
 	// Build function map by ID for quick lookup
@@ -32,49 +52,46 @@ func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, e
 	// Track function data by function ID for accurate aggregation
 	functionData := make(map[uint64]*FunctionData)
 
+	// Track caller -> callee edge weights by function name
+	callers := make(map[string]map[string]int64)
+	callees := make(map[string]map[string]int64)
+
 	// Process samples
 	for _, sample := range prof.Sample {
 		if len(sample.Value) == 0 {
 			continue
 		}
 
-		// Get value based on profile type
-		var value int64
-		var value2 int64 // For profiles with 2 values
+		// Primary metric, converted to the unit of the metric
+		metricValue := layout.value(sample, layout.metric) * layout.scale
+		result.TotalSamples += metricValue
 
+		// Type specific stats, read by sample type name
 		switch profileType {
-		case TypeCPU:
-			value = sample.Value[0] * prof.Period // scale to nanoseconds
-			result.TotalSamples += value
 		case TypeHeap:
-			// Heap profiles: 4 values = [alloc_objects, alloc_space, inuse_objects, inuse_space]
-			//                 2 values = [alloc_objects, alloc_space] (inuse stays 0)
-			if len(sample.Value) >= 2 {
-				result.Stats.AllocObjects += sample.Value[0]
-				result.Stats.AllocBytes += sample.Value[1]
-				value = sample.Value[0]  // objects
-				value2 = sample.Value[1] // bytes
-			}
-			if len(sample.Value) >= 4 {
-				result.Stats.InUseObjects += sample.Value[2]
-				result.Stats.InUseBytes += sample.Value[3]
-			}
+			result.Stats.AllocObjects += layout.value(sample, layout.allocObjects)
+			result.Stats.AllocBytes += layout.value(sample, layout.allocSpace)
+			result.Stats.InUseObjects += layout.value(sample, layout.inuseObjects)
+			result.Stats.InUseBytes += layout.value(sample, layout.inuseSpace)
 		case TypeGoroutine:
-			value = sample.Value[0] // count
-			result.Stats.TotalGoroutines += value
-			result.TotalSamples += value
-		case TypeMutex:
-			// Mutex samples: [contentions (count), lock_duration (nanoseconds)]
-			if len(sample.Value) >= 2 {
-				value = sample.Value[1]  // lock duration (nanoseconds) - primary metric
-				value2 = sample.Value[0] // contentions count
-				result.Stats.TotalContentionTime += value
-				result.Stats.TotalWaits += value2
-			}
+			result.Stats.TotalGoroutines += layout.value(sample, layout.goroutines)
+		case TypeMutex, TypeSched, TypeSyscall, TypeNet, TypeSync:
+			// Mutex samples: [contentions (count), delay (nanoseconds)]
+			// Profiles derived from execution traces use the same layout
+			result.Stats.TotalContentionTime += layout.value(sample, layout.delay) * layout.delayScale
+			result.Stats.TotalWaits += layout.value(sample, layout.contentions)
 		}
 
 		// Build call stack
 		callStack := buildCallStackFromSample(sample, funcMap)
+		result.Samples = append(result.Samples, Sample{
+			Stack:     callStack,
+			Value:     metricValue,
+			Values:    sample.Value,
+			CreatedBy: firstLabel(sample, createdByLabel),
+		})
+
+		addEdges(callStack, metricValue, callers, callees)
 
 		// Process each location in the stack
 		for i, loc := range sample.Location {
@@ -97,6 +114,7 @@ func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, e
 						File:      fn.Filename,
 						Line:      int(line.Line),
 						CallStack: callStack,
+						lines:     make(map[int]*LineValue),
 					}
 					functionData[fn.ID] = data
 				}
@@ -105,13 +123,15 @@ func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, e
 				// In pprof, Location[0] is the leaf (innermost frame)
 				isLeaf := (i == 0)
 
-				// Use bytes for heap profiles as primary metric
-				metricValue := value
-				if profileType == TypeHeap {
-					metricValue = value2
+				lv := data.lines[int(line.Line)]
+				if lv == nil {
+					lv = &LineValue{Line: int(line.Line)}
+					data.lines[lv.Line] = lv
 				}
+				lv.Cum += metricValue
 
 				if isLeaf {
+					lv.Flat += metricValue
 					data.Flat += metricValue
 					// Track this call path for the leaf function
 					data.CallPaths = append(data.CallPaths, CallPath{
@@ -124,25 +144,9 @@ func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, e
 		}
 	}
 
-	// Set total samples based on profile type
-	switch profileType {
-	case TypeHeap:
-		result.TotalSamples = result.Stats.AllocBytes
-	case TypeMutex:
-		result.TotalSamples = result.Stats.TotalContentionTime
-	}
-
 	// Convert to Function slice and calculate percentages
+	total := result.TotalSamples
 	for _, data := range functionData {
-		var total int64
-		switch profileType {
-		case TypeHeap:
-			total = result.Stats.AllocBytes
-		case TypeMutex:
-			total = result.Stats.TotalContentionTime
-		default:
-			total = result.TotalSamples
-		}
 
 		// Merge duplicate call paths and sort by weight descending
 		callPaths := mergeCallPaths(data.CallPaths)
@@ -164,6 +168,9 @@ func convertProfile(prof *profile.Profile, profileType ProfileType) (*Profile, e
 			Cum:       data.Cum,
 			CallStack: callStack,
 			CallPaths: callPaths,
+			Callers:   sortedEdges(callers[data.Name]),
+			Callees:   sortedEdges(callees[data.Name]),
+			Lines:     sortedLines(data.lines),
 		}
 
 		if total > 0 {
@@ -209,6 +216,28 @@ type FunctionData struct {
 	Cum       int64
 	CallStack []string
 	CallPaths []CallPath
+
+	lines map[int]*LineValue // Values by source line
+}
+
+// sortedLines returns line values heaviest first: by flat, then cumulative
+// value, then line number
+func sortedLines(lines map[int]*LineValue) []LineValue {
+	result := make([]LineValue, 0, len(lines))
+	for _, lv := range lines {
+		result = append(result, *lv)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		return a.Line < b.Line
+	})
+	return result
 }
 
 // buildCallStackFromSample builds a call stack from a sample
@@ -235,6 +264,56 @@ func buildCallStackFromSample(sample *profile.Sample, funcMap map[uint64]*profil
 	return stack
 }
 
+// firstLabel returns the first value of a sample's string label, or ""
+func firstLabel(sample *profile.Sample, key string) string {
+	if values := sample.Label[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// addEdges adds the weight of a sample to each caller -> callee edge in its
+// stack. An edge that appears more than once, as in recursion, is counted
+// once per sample.
+func addEdges(stack []string, weight int64, callers, callees map[string]map[string]int64) {
+	seen := make(map[[2]string]bool)
+	for i := 1; i < len(stack); i++ {
+		edge := [2]string{stack[i-1], stack[i]}
+		if seen[edge] {
+			continue
+		}
+		seen[edge] = true
+
+		caller, callee := edge[0], edge[1]
+		if callees[caller] == nil {
+			callees[caller] = make(map[string]int64)
+		}
+		callees[caller][callee] += weight
+		if callers[callee] == nil {
+			callers[callee] = make(map[string]int64)
+		}
+		callers[callee][caller] += weight
+	}
+}
+
+// sortedEdges converts edge weights by name into edges, heaviest first
+func sortedEdges(weights map[string]int64) []Edge {
+	if len(weights) == 0 {
+		return nil
+	}
+	edges := make([]Edge, 0, len(weights))
+	for name, weight := range weights {
+		edges = append(edges, Edge{Name: name, Weight: weight})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Weight != edges[j].Weight {
+			return edges[i].Weight > edges[j].Weight
+		}
+		return edges[i].Name < edges[j].Name
+	})
+	return edges
+}
+
 // mergeCallPaths merges duplicate call paths by summing their weights
 func mergeCallPaths(paths []CallPath) []CallPath {
 	if len(paths) == 0 {
@@ -256,72 +335,3 @@ func mergeCallPaths(paths []CallPath) []CallPath {
 	}
 	return result
 }
-
-// detectProfileTypeFromSampleType detects profile type from sample type
-func detectProfileTypeFromSampleType(prof *profile.Profile) (ProfileType, error) {
-	if len(prof.SampleType) == 0 {
-		return "", fmt.Errorf("no sample type in profile")
-	}
-
-	// Check sample types for known patterns
-	for _, st := range prof.SampleType {
-		typeStr := st.Type
-		unitStr := st.Unit
-
-		switch typeStr {
-		case "cpu", "samples":
-			if unitStr == "nanoseconds" || unitStr == "seconds" || unitStr == "count" {
-				return TypeCPU, nil
-			}
-		case "alloc_objects", "inuse_objects", "alloc_space", "inuse_space":
-			return TypeHeap, nil
-		case "goroutines":
-			return TypeGoroutine, nil
-		case "contentions", "lock_duration":
-			return TypeMutex, nil
-		}
-
-		// Fallback: check unit
-		switch unitStr {
-		case "nanoseconds", "seconds", "milliseconds":
-			// Could be CPU or mutex, check type name
-			if typeStr == "cpu" || typeStr == "samples" {
-				return TypeCPU, nil
-			}
-		case "bytes":
-			return TypeHeap, nil
-		case "objects", "count":
-			if typeStr == "goroutines" {
-				return TypeGoroutine, nil
-			}
-		case "lock_ns", "contentions":
-			return TypeMutex, nil
-		}
-	}
-
-	// Try period type
-	if prof.PeriodType != nil {
-		if prof.PeriodType.Type == "cpu" || prof.PeriodType.Type == "samples" {
-			return TypeCPU, nil
-		}
-	}
-
-	// Default: try to infer from sample value count
-	if len(prof.Sample) > 0 {
-		valueCount := len(prof.Sample[0].Value)
-		if valueCount == 2 {
-			// Could be heap or mutex
-			// Check sample type names
-			for _, st := range prof.SampleType {
-				if st.Type == "alloc_objects" || st.Type == "inuse_objects" {
-					return TypeHeap, nil
-				}
-				if st.Type == "contentions" {
-					return TypeMutex, nil
-				}
-			}
-		}
-	}
-
-	return "", fmt.Errorf("unknown profile type: sample types: %v", prof.SampleType)
-}
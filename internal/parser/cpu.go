@@ -3,27 +3,20 @@ package parser
 import (
 	"fmt"
 	"os"
-
-	"github.com/google/pprof/profile"
 )
 
 // CPUParser parses CPU pprof profiles
 type CPUParser struct{}
 
-// Parse parses a CPU profile file
+// Parse parses a CPU profile file: a binary profile, `perf script` output
+// or folded stacks
 func (p *CPUParser) Parse(filename string) (*Profile, error) {
-	f, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open profile file: %w", err)
 	}
-	defer f.Close()
-
-	prof, err := profile.Parse(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse profile: %w", err)
-	}
 
-	return convertProfile(prof, TypeCPU)
+	return ParseData(data, TypeCPU)
 }
 
 // DetectType always returns TypeCPU for CPUParser
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// minDetectScore is the score a profile type needs to be chosen; profiles
+// with weaker evidence are reported as generic
+const minDetectScore = 2
+
+// strongDetectScore is the score at which the evidence for a profile type is
+// conclusive, such as a Go sample type together with its period type
+const strongDetectScore = 4
+
+// Detection is the result of profile type detection
+type Detection struct {
+	Type       ProfileType
+	Format     string           // e.g. "pprof" or "goroutine dump"
+	Confidence float64          // From 0 to 1
+	Candidates []DetectionScore // Profile types with any evidence, best first
+}
+
+// DetectionScore is the evidence for one profile type
+type DetectionScore struct {
+	Type    ProfileType
+	Score   int
+	Reasons []string
+}
+
+// sampleTypeProfiles maps the sample and period type names written by the
+// Go runtime to the profile type they belong to. "samples" is left out: it
+// is generic and only counts for CPU next to CPU time.
+var sampleTypeProfiles = map[string]ProfileType{
+	"cpu":           TypeCPU,
+	"alloc_objects": TypeHeap,
+	"alloc_space":   TypeHeap,
+	"inuse_objects": TypeHeap,
+	"inuse_space":   TypeHeap,
+	"space":         TypeHeap,
+	"goroutine":     TypeGoroutine,
+	"goroutines":    TypeGoroutine,
+	"contentions":   TypeMutex,
+	"delay":         TypeMutex,
+	"lock_duration": TypeMutex,
+}
+
+// commentKeywords maps words in profile comments to profile types
+var commentKeywords = map[string]ProfileType{
+	"cpu":        TypeCPU,
+	"heap":       TypeHeap,
+	"memory":     TypeHeap,
+	"allocation": TypeHeap,
+	"goroutine":  TypeGoroutine,
+	"mutex":      TypeMutex,
+	"contention": TypeMutex,
+	"block":      TypeMutex,
+}
+
+// textDetection returns the detection of a text or trace format, which
+// determines the profile type by itself
+func textDetection(profileType ProfileType, format, reason string) *Detection {
+	return &Detection{
+		Type:       profileType,
+		Format:     format,
+		Confidence: 1,
+		Candidates: []DetectionScore{{Type: profileType, Score: minDetectScore, Reasons: []string{reason}}},
+	}
+}
+
+// scoreProfileTypes scores each profile type by the evidence in all sample
+// types, the period type, the default sample type and the comments of a
+// pprof profile. Comments only corroborate: they add to profile types that
+// already reach minDetectScore from the sample and period types. Confidence
+// is the best score's share of all scores, scaled down while the best score
+// is below strongDetectScore; for generic profiles it is how far the best
+// score falls short of minDetectScore.
+func scoreProfileTypes(prof *profile.Profile) (*Detection, error) {
+	if len(prof.SampleType) == 0 {
+		return nil, fmt.Errorf("no sample type in profile")
+	}
+
+	scores := make(map[ProfileType]*DetectionScore)
+	add := func(profileType ProfileType, points int, format string, args ...interface{}) {
+		s, ok := scores[profileType]
+		if !ok {
+			s = &DetectionScore{Type: profileType}
+			scores[profileType] = s
+		}
+		s.Score += points
+		s.Reasons = append(s.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	cpuTime := prof.PeriodType != nil && prof.PeriodType.Type == "cpu"
+	for _, st := range prof.SampleType {
+		if st.Type == "cpu" && timeUnitScale(st.Unit) > 0 {
+			cpuTime = true
+		}
+	}
+
+	for _, st := range prof.SampleType {
+		isTime := timeUnitScale(st.Unit) > 0
+		switch profileType, known := sampleTypeProfiles[st.Type]; {
+		case st.Type == "cpu" && isTime:
+			add(TypeCPU, 3, "sample type `cpu` (%s) measures CPU time", st.Unit)
+		case st.Type == "samples" && cpuTime:
+			add(TypeCPU, 1, "sample type `samples` (%s) counts CPU samples", st.Unit)
+		case known && st.Type != "cpu":
+			add(profileType, 2, "sample type `%s` (%s) is written by Go %s profiles", st.Type, st.Unit, profileType)
+		case st.Unit == "bytes":
+			add(TypeHeap, 1, "sample type `%s` is measured in bytes", st.Type)
+		}
+	}
+
+	if pt := prof.PeriodType; pt != nil {
+		if profileType, ok := sampleTypeProfiles[pt.Type]; ok {
+			add(profileType, 2, "period type `%s` (%s)", pt.Type, pt.Unit)
+		}
+	}
+
+	if profileType, ok := sampleTypeProfiles[prof.DefaultSampleType]; ok {
+		add(profileType, 1, "default sample type `%s`", prof.DefaultSampleType)
+	}
+
+	for _, comment := range prof.Comments {
+		lower := strings.ToLower(comment)
+		for keyword, profileType := range commentKeywords {
+			if s, ok := scores[profileType]; ok && s.Score >= minDetectScore && strings.Contains(lower, keyword) {
+				add(profileType, 1, "comment %q mentions %q", comment, keyword)
+			}
+		}
+	}
+
+	d := &Detection{Format: "pprof"}
+	total := 0
+	for _, s := range scores {
+		sort.Strings(s.Reasons)
+		d.Candidates = append(d.Candidates, *s)
+		total += s.Score
+	}
+	sort.Slice(d.Candidates, func(i, j int) bool {
+		if d.Candidates[i].Score != d.Candidates[j].Score {
+			return d.Candidates[i].Score > d.Candidates[j].Score
+		}
+		return d.Candidates[i].Type < d.Candidates[j].Type
+	})
+
+	if len(d.Candidates) == 0 || d.Candidates[0].Score < minDetectScore {
+		best := 0
+		if len(d.Candidates) > 0 {
+			best = d.Candidates[0].Score
+		}
+		d.Type = TypeGeneric
+		d.Confidence = 1 - float64(best)/minDetectScore
+		return d, nil
+	}
+
+	d.Type = d.Candidates[0].Type
+	best := d.Candidates[0].Score
+	d.Confidence = float64(best) / float64(total) * min(1, float64(best)/strongDetectScore)
+	return d, nil
+}
+
+// Explain describes the detection as markdown: the chosen type and the
+// evidence for each candidate
+func (d *Detection) Explain() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Detected type: %s (confidence %.0f%%)\n", d.Type, d.Confidence*100)
+	fmt.Fprintf(&sb, "Format: %s\n", d.Format)
+	if d.Type == TypeGeneric {
+		fmt.Fprintf(&sb, "No profile type scored at least %d, so the profile is reported generically.\n", minDetectScore)
+	}
+	if len(d.Candidates) > 0 {
+		sb.WriteString("\nCandidates:\n")
+	}
+	for _, c := range d.Candidates {
+		fmt.Fprintf(&sb, "- %s (score %d)\n", c.Type, c.Score)
+		for _, reason := range c.Reasons {
+			fmt.Fprintf(&sb, "  - %s\n", reason)
+		}
+	}
+	return sb.String()
+}
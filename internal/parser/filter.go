@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter returns the profile restricted to samples with a frame matching
+// focus and no frame matching ignore, like `go tool pprof -focus` and
+// `-ignore`. Either may be nil. Totals and percentages are recomputed.
+func (p *Profile) Filter(focus, ignore *regexp.Regexp) (*Profile, error) {
+	if focus == nil && ignore == nil {
+		return p, nil
+	}
+	if p.source == nil {
+		return nil, fmt.Errorf("%s profile has no samples to filter", p.Type)
+	}
+
+	source := p.source.Copy()
+	source.FilterSamplesByName(focus, ignore, nil, nil)
+	result, err := convertProfileMetric(source, p.Type, p.sampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	result.Trace = p.Trace
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importedSamplePeriod is the sampling period assumed for imported stacks
+// that only carry sample counts. It matches Go's default CPU profiling rate
+// of 100 Hz, so durations are estimates while percentages are exact.
+const importedSamplePeriod = 10 * time.Millisecond
+
+// foldedLineRE matches a line of folded stacks, e.g. "main;work;hash 42"
+var foldedLineRE = regexp.MustCompile(`^(\S.*) (\d+)$`)
+
+// maxDetectLines is the number of lines inspected to detect text formats
+const maxDetectLines = 10
+
+// isFoldedStacks reports whether data looks like Brendan Gregg's collapsed
+// stack format, as written by stackcollapse-perf.pl or `--format folded`
+func isFoldedStacks(data []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lines := 0
+	for s.Scan() && lines < maxDetectLines {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !foldedLineRE.MatchString(line) {
+			return false
+		}
+		lines++
+	}
+	return lines > 0
+}
+
+// parseFoldedStacks parses collapsed stacks into a CPU profile. Each line is
+// a semicolon-separated stack, root first, followed by a sample count.
+func parseFoldedStacks(data []byte) (*Profile, error) {
+	b := newTextProfileBuilder("samples", "count", importedSamplePeriod.Nanoseconds(), "cpu", "nanoseconds")
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for s.Scan() {
+		lineNo++
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := foldedLineRE.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid folded stack on line %d: %q", lineNo, line)
+		}
+		count, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample count on line %d: %w", lineNo, err)
+		}
+
+		names := strings.Split(m[1], ";")
+		frames := make([]dumpFrame, 0, len(names))
+		for i := len(names) - 1; i >= 0; i-- {
+			if names[i] != "" {
+				frames = append(frames, dumpFrame{Function: names[i]})
+			}
+		}
+		if len(frames) > 0 && count > 0 {
+			b.add("", frames, count)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read folded stacks: %w", err)
+	}
+	if len(b.prof.Sample) == 0 {
+		return nil, fmt.Errorf("no stacks found in folded input")
+	}
+
+	return convertProfile(b.prof, TypeCPU)
+}
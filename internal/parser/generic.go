@@ -0,0 +1,25 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+)
+
+// GenericParser parses profiles of any other type, such as custom
+// pprof.NewProfile profiles or profiles from non-Go producers
+type GenericParser struct{}
+
+// Parse parses a profile file of any sample types
+func (p *GenericParser) Parse(filename string) (*Profile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile file: %w", err)
+	}
+
+	return ParseData(data, TypeGeneric)
+}
+
+// DetectType always returns TypeGeneric for GenericParser
+func (p *GenericParser) DetectType() (ProfileType, error) {
+	return TypeGeneric, nil
+}
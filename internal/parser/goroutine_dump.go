@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goroutineHeaderRE matches the first line of each goroutine in a
+// debug=2 dump, e.g. "goroutine 18 [chan receive, 5 minutes]:"
+var goroutineHeaderRE = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[([^\]]*)\]:$`)
+
+// createdByLabel is the sample label recording the "created by" site of
+// goroutines read from a dump
+const createdByLabel = "created_by"
+
+// dumpFrame is a single frame of a goroutine dump
+type dumpFrame struct {
+	Function string
+	File     string
+	Line     int64
+}
+
+// dumpGoroutine is a single goroutine of a goroutine dump
+type dumpGoroutine struct {
+	Frames    []dumpFrame // Leaf first
+	CreatedBy string
+}
+
+// isGoroutineDump reports whether data looks like a debug=2 goroutine dump
+// (the format of /debug/pprof/goroutine?debug=2 and panic tracebacks). A
+// traceback starts with a "panic:" or "fatal error:" message, which is
+// skipped for up to maxDetectLines lines to find the first goroutine.
+func isGoroutineDump(data []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	lines := 0
+	preamble := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if goroutineHeaderRE.MatchString(line) {
+			return true
+		}
+		if lines == 0 {
+			preamble = strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ")
+		}
+		lines++
+		if !preamble || lines >= maxDetectLines {
+			return false
+		}
+	}
+	return false
+}
+
+// parseGoroutineDump parses a debug=2 goroutine dump. Goroutines with the
+// same stack and creation site are merged into a single sample.
+func parseGoroutineDump(data []byte) (*Profile, error) {
+	goroutines, err := scanGoroutineDump(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(goroutines) == 0 {
+		return nil, fmt.Errorf("no goroutines found in dump")
+	}
+
+	b := newTextProfileBuilder("goroutines", "count", 1, "goroutines", "count")
+	for _, g := range goroutines {
+		if b.add(g.CreatedBy, g.Frames, 1) && g.CreatedBy != "" {
+			sample := b.prof.Sample[len(b.prof.Sample)-1]
+			sample.Label = map[string][]string{createdByLabel: {g.CreatedBy}}
+		}
+	}
+
+	return convertProfile(b.prof, TypeGoroutine)
+}
+
+// scanGoroutineDump splits a goroutine dump into goroutines and frames
+func scanGoroutineDump(data []byte) ([]dumpGoroutine, error) {
+	var goroutines []dumpGoroutine
+	var current *dumpGoroutine
+	// pending is the frame whose file:line is expected on the next line
+	var pending *dumpFrame
+	inCreatedBy := false
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		raw := s.Text()
+		line := strings.TrimSpace(raw)
+
+		switch {
+		case line == "":
+			pending = nil
+			current = nil
+		case goroutineHeaderRE.MatchString(line):
+			goroutines = append(goroutines, dumpGoroutine{})
+			current = &goroutines[len(goroutines)-1]
+			pending = nil
+			inCreatedBy = false
+		case current == nil:
+			// Text outside a goroutine block, e.g. a panic message
+		case strings.HasPrefix(raw, "\t") || strings.HasPrefix(raw, " "):
+			file, lineNo := parseDumpFileLine(line)
+			if pending != nil {
+				pending.File = file
+				pending.Line = lineNo
+				current.Frames = append(current.Frames, *pending)
+				pending = nil
+			}
+		case strings.HasPrefix(line, "created by "):
+			site := strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(site, " in goroutine "); i >= 0 {
+				site = site[:i]
+			}
+			current.CreatedBy = site
+			pending = nil
+			inCreatedBy = true
+		case strings.HasPrefix(line, "..."):
+			// "...additional frames elided..."
+		default:
+			if inCreatedBy {
+				continue
+			}
+			pending = &dumpFrame{Function: trimDumpArgs(line)}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read goroutine dump: %w", err)
+	}
+	return goroutines, nil
+}
+
+// trimDumpArgs strips the argument list from a frame such as
+// "main.(*Server).handle(0xc000010000, 0x1)"
+func trimDumpArgs(line string) string {
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndex(line, "("); i > 0 {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseDumpFileLine parses "/path/to/file.go:42 +0x1d" into file and line
+func parseDumpFileLine(line string) (string, int64) {
+	if i := strings.Index(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return line, 0
+	}
+	n, err := strconv.ParseInt(line[i+1:], 10, 64)
+	if err != nil {
+		return line, 0
+	}
+	return line[:i], n
+}
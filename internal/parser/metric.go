@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// sampleLayout locates the sample values a profile type reads by sample type
+// name, so profiles with extra, reordered or custom sample types convert
+type sampleLayout struct {
+	metric int   // Index of the primary metric
+	scale  int64 // Factor converting the primary metric to unit
+	unit   string
+
+	// Indexes of the values summarized in Stats, -1 when absent
+	allocObjects, allocSpace, inuseObjects, inuseSpace int
+	goroutines                                         int
+	contentions, delay                                 int
+	delayScale                                         int64
+}
+
+// newSampleLayout resolves the sample types read for profileType. The
+// primary metric is the named sample type if sampleIndex is set, and the
+// profile type's usual metric otherwise.
+func newSampleLayout(prof *profile.Profile, profileType ProfileType, sampleIndex string) (sampleLayout, error) {
+	if len(prof.SampleType) == 0 {
+		return sampleLayout{}, fmt.Errorf("no sample type in profile")
+	}
+
+	index := func(names ...string) int { return sampleTypeIndex(prof, names...) }
+	isTime := func(unit string) bool { return timeUnitScale(unit) > 0 }
+
+	l := sampleLayout{
+		allocObjects: index("alloc_objects"),
+		allocSpace:   index("alloc_space"),
+		inuseObjects: index("inuse_objects"),
+		inuseSpace:   index("inuse_space"),
+		goroutines:   index("goroutine", "goroutines"),
+		contentions:  index("contentions"),
+		delay:        index("delay", "lock_duration"),
+		delayScale:   1,
+	}
+	if l.goroutines < 0 {
+		l.goroutines = 0
+	}
+	if l.contentions < 0 {
+		l.contentions = sampleUnitIndex(prof, func(unit string) bool { return unit == "count" })
+	}
+	if l.delay < 0 {
+		l.delay = sampleUnitIndex(prof, isTime)
+	}
+	if l.delay >= 0 {
+		l.delayScale = timeUnitScale(prof.SampleType[l.delay].Unit)
+	}
+
+	if sampleIndex != "" {
+		l.metric = index(sampleIndex)
+		if l.metric < 0 {
+			names := make([]string, len(prof.SampleType))
+			for i, st := range prof.SampleType {
+				names[i] = st.Type
+			}
+			return sampleLayout{}, fmt.Errorf("unknown sample type %q (available: %s)", sampleIndex, strings.Join(names, ", "))
+		}
+	} else {
+		l.metric = defaultMetric(prof, profileType, l)
+	}
+
+	st := prof.SampleType[l.metric]
+	l.scale, l.unit = 1, st.Unit
+	switch {
+	case isTime(st.Unit):
+		l.scale, l.unit = timeUnitScale(st.Unit), "nanoseconds"
+	case profileType == TypeCPU && sampleIndex == "" && prof.PeriodType != nil && isTime(prof.PeriodType.Unit) && prof.Period > 0:
+		// Sample counts of a CPU profile without a time sample type
+		l.scale, l.unit = prof.Period*timeUnitScale(prof.PeriodType.Unit), "nanoseconds"
+	}
+	return l, nil
+}
+
+// defaultMetric returns the index of the usual primary metric of a profile
+// type: CPU time, allocated bytes, goroutines or delay. Other profiles use
+// their default sample type, or the last one as pprof does.
+func defaultMetric(prof *profile.Profile, profileType ProfileType, l sampleLayout) int {
+	metric := -1
+	switch profileType {
+	case TypeCPU:
+		if metric = sampleTypeIndex(prof, "cpu"); metric < 0 || timeUnitScale(prof.SampleType[metric].Unit) == 0 {
+			metric = sampleTypeIndex(prof, "samples")
+		}
+	case TypeHeap:
+		if metric = l.allocSpace; metric < 0 {
+			metric = l.inuseSpace
+		}
+		if metric < 0 {
+			metric = sampleUnitIndex(prof, func(unit string) bool { return unit == "bytes" })
+		}
+	case TypeGoroutine:
+		metric = l.goroutines
+	case TypeMutex, TypeSched, TypeSyscall, TypeNet, TypeSync:
+		metric = l.delay
+	default:
+		if metric = sampleTypeIndex(prof, prof.DefaultSampleType); prof.DefaultSampleType == "" || metric < 0 {
+			metric = len(prof.SampleType) - 1
+		}
+	}
+	if metric < 0 {
+		metric = 0
+	}
+	return metric
+}
+
+// sampleTypeIndex returns the index of the first of names found among the
+// profile's sample types, or -1
+func sampleTypeIndex(prof *profile.Profile, names ...string) int {
+	for _, name := range names {
+		for i, st := range prof.SampleType {
+			if st.Type == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// sampleUnitIndex returns the index of the first sample type whose unit
+// matches, or -1
+func sampleUnitIndex(prof *profile.Profile, match func(unit string) bool) int {
+	for i, st := range prof.SampleType {
+		if match(st.Unit) {
+			return i
+		}
+	}
+	return -1
+}
+
+// value returns the value at index i of a sample, or 0 if it has none
+func (l sampleLayout) value(sample *profile.Sample, i int) int64 {
+	if i < 0 || i >= len(sample.Value) {
+		return 0
+	}
+	return sample.Value[i]
+}
+
+// metricType describes the primary metric in the unit values are converted to
+func (l sampleLayout) metricType(prof *profile.Profile) SampleType {
+	return SampleType{Type: prof.SampleType[l.metric].Type, Unit: l.unit}
+}
+
+// timeUnitScale returns the factor converting a pprof time unit to
+// nanoseconds, or 0 if unit is not a time unit
+func timeUnitScale(unit string) int64 {
+	switch unit {
+	case "nanoseconds", "ns":
+		return 1
+	case "microseconds", "us":
+		return 1_000
+	case "milliseconds", "ms":
+		return 1_000_000
+	case "seconds", "s":
+		return 1_000_000_000
+	default:
+		return 0
+	}
+}
+
+// SelectSampleType returns the profile converted again with the named
+// sample type as its primary metric, like `go tool pprof -sample_index`
+func (p *Profile) SelectSampleType(name string) (*Profile, error) {
+	if name == "" {
+		return p, nil
+	}
+	if p.source == nil {
+		if name == p.Metric.Type {
+			return p, nil
+		}
+		return nil, fmt.Errorf("unknown sample type %q", name)
+	}
+
+	result, err := convertProfileMetric(p.source, p.Type, name)
+	if err != nil {
+		return nil, err
+	}
+	result.Trace = p.Trace
+	return result, nil
+}
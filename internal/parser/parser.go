@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"time"
@@ -16,15 +17,61 @@ const (
 	TypeHeap      ProfileType = "heap"
 	TypeGoroutine ProfileType = "goroutine"
 	TypeMutex     ProfileType = "mutex"
+
+	// Profiles derived from execution traces, named after the
+	// `go tool trace -pprof` types
+	TypeSched   ProfileType = "sched"   // Scheduler latency
+	TypeSyscall ProfileType = "syscall" // Time in system calls
+	TypeNet     ProfileType = "net"     // Network blocking
+	TypeSync    ProfileType = "sync"    // Synchronization blocking
+
+	// TypeGeneric is any other profile, such as a custom pprof.NewProfile
+	// profile or one from a non-Go producer
+	TypeGeneric ProfileType = "generic"
 )
 
 // Profile represents the parsed pprof data
 type Profile struct {
 	Type        ProfileType
+	Time        time.Time // Collection time (zero if the profile has no timestamp)
 	SampleTime  time.Duration
 	TotalSamples int64
 	Functions   []Function
 	Stats       Stats
+	SampleTypes []SampleType // Value types recorded with each sample
+	Samples     []Sample     // Raw samples, one per distinct stack in the source profile
+	Trace       *TraceSummary // Execution trace summary (nil unless read from a trace)
+	Metric      SampleType    // Primary metric; time metrics are converted to nanoseconds
+
+	source      *profile.Profile // Source profile, to select another sample type or filter
+	sampleIndex string           // Sample type selected when converting, empty for the default
+}
+
+// SampleType describes one of the values recorded with each sample
+type SampleType struct {
+	Type string // e.g. "inuse_space"
+	Unit string // e.g. "bytes"
+}
+
+// Sample represents a single stack sample from the source profile
+type Sample struct {
+	Stack  []string // Call stack, root first
+	Value  int64    // Primary metric value (same unit as Function.Flat)
+	Values []int64  // Raw values, in SampleTypes order
+
+	// CreatedBy is the "created by" site of goroutine dump samples.
+	// Binary goroutine profiles do not record it.
+	CreatedBy string
+}
+
+// SampleTypeIndex returns the index of the named sample type, or -1 if absent
+func (p *Profile) SampleTypeIndex(name string) int {
+	for i, st := range p.SampleTypes {
+		if st.Type == name {
+			return i
+		}
+	}
+	return -1
 }
 
 // CallPath represents a single call path with its weight
@@ -45,6 +92,23 @@ type Function struct {
 	SumPct    float64  // Cumulative sum of FlatPct (running total)
 	CallStack []string // Call stack (heaviest path, for backward compat)
 	CallPaths []CallPath // All call paths with weights
+	Callers   []Edge     // Immediate callers, heaviest first
+	Callees   []Edge     // Immediate callees, heaviest first
+	Lines     []LineValue // Values by source line, heaviest first
+}
+
+// LineValue is the weight sampled at one source line of a function
+type LineValue struct {
+	Line int
+	Flat int64
+	Cum  int64
+}
+
+// Edge is the weight flowing between a function and an immediate caller or
+// callee
+type Edge struct {
+	Name   string
+	Weight int64
 }
 
 // Stats contains summary statistics
@@ -88,6 +152,10 @@ func NewParser(profileType ProfileType) Parser {
 		return &GoroutineParser{}
 	case TypeMutex:
 		return &MutexParser{}
+	case TypeSched, TypeSyscall, TypeNet, TypeSync:
+		return &TraceParser{Type: profileType}
+	case TypeGeneric:
+		return &GenericParser{}
 	default:
 		return nil
 	}
@@ -95,19 +163,89 @@ func NewParser(profileType ProfileType) Parser {
 
 // DetectProfileType auto-detects the profile type from file content
 func DetectProfileType(filename string) (ProfileType, error) {
-	f, err := os.Open(filename)
+	d, err := DetectProfile(filename)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return "", err
+	}
+	return d.Type, nil
+}
+
+// DetectProfile detects the format and profile type of a file, with the
+// evidence for each candidate type
+func DetectProfile(filename string) (*Detection, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return DetectData(data)
+}
+
+// DetectData detects the format and profile type of profile data
+func DetectData(data []byte) (*Detection, error) {
+	// Text goroutine dumps (debug=2) are not understood by the pprof library
+	if isGoroutineDump(data) {
+		return textDetection(TypeGoroutine, "goroutine dump", "starts with a debug=2 goroutine header"), nil
+	}
+
+	// Execution traces default to the scheduler latency profile
+	if isExecutionTrace(data) {
+		return textDetection(TypeSched, "execution trace", "starts with an execution trace header; sched is the default trace profile"), nil
+	}
+
+	// Stacks imported from Linux perf are CPU samples
+	if isPerfScript(data) {
+		return textDetection(TypeCPU, "perf script", "sample headers followed by indented frames"), nil
+	}
+	if isFoldedStacks(data) {
+		return textDetection(TypeCPU, "folded stacks", "lines of semicolon-separated frames and a count"), nil
 	}
-	defer f.Close()
 
 	// Use official pprof library to parse
-	prof, err := profile.Parse(f)
+	prof, err := profile.ParseData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %w", err)
+	}
+
+	return scoreProfileTypes(prof)
+}
+
+// ParseData parses profile data of the given type, or of the detected type
+// if profileType is empty. It accepts every format the file parsers do.
+func ParseData(data []byte, profileType ProfileType) (*Profile, error) {
+	if profileType == "" {
+		d, err := DetectData(data)
+		if err != nil {
+			return nil, err
+		}
+		profileType = d.Type
+	}
+
+	switch profileType {
+	case TypeCPU, TypeHeap, TypeGoroutine, TypeMutex, TypeGeneric:
+	case TypeSched, TypeSyscall, TypeNet, TypeSync:
+		if !isExecutionTrace(data) {
+			return nil, fmt.Errorf("not a Go execution trace")
+		}
+		return parseExecutionTrace(bytes.NewReader(data), profileType)
+	default:
+		return nil, fmt.Errorf("unsupported profile type: %s", profileType)
+	}
+
+	switch {
+	case profileType == TypeGoroutine && isGoroutineDump(data):
+		return parseGoroutineDump(data)
+	case profileType == TypeCPU && isPerfScript(data):
+		return parsePerfScript(data)
+	case profileType == TypeCPU && isFoldedStacks(data):
+		return parseFoldedStacks(data)
+	}
+
+	prof, err := profile.ParseData(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse profile: %w", err)
+		return nil, fmt.Errorf("failed to parse profile: %w", err)
 	}
 
-	return detectProfileTypeFromSampleType(prof)
+	return convertProfile(prof, profileType)
 }
 
 // Parse parses a pprof file with auto-detected type
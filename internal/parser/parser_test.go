@@ -1,9 +1,17 @@
 package parser
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime/trace"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
 )
 
 // TestDetectProfileType tests profile type detection
@@ -101,6 +109,19 @@ func TestParseHeapProfile(t *testing.T) {
 	if prof.Stats.AllocObjects == 0 {
 		t.Error("expected non-zero alloc objects")
 	}
+
+	// Raw samples keep every value so in-use data stays available
+	if idx := prof.SampleTypeIndex("inuse_space"); idx != 3 {
+		t.Errorf("inuse_space index = %d, want 3", idx)
+	}
+	if len(prof.Samples) == 0 {
+		t.Fatal("expected at least one sample")
+	}
+	for _, s := range prof.Samples {
+		if len(s.Values) != len(prof.SampleTypes) {
+			t.Errorf("sample has %d values, want %d", len(s.Values), len(prof.SampleTypes))
+		}
+	}
 }
 
 // TestParseGoroutineProfile tests goroutine profile parsing
@@ -287,3 +308,771 @@ func TestInvalidProfileFile(t *testing.T) {
 		t.Error("expected error when parsing invalid profile file")
 	}
 }
+
+// TestConvertProfileTime tests that the collection timestamp is preserved
+func TestConvertProfileTime(t *testing.T) {
+	collected := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "goroutines", Unit: "count"}},
+		TimeNanos:  collected.UnixNano(),
+	}
+
+	result, err := convertProfile(prof, TypeGoroutine)
+	if err != nil {
+		t.Fatalf("convertProfile failed: %v", err)
+	}
+	if !result.Time.Equal(collected) {
+		t.Errorf("got time %v, want %v", result.Time, collected)
+	}
+
+	prof.TimeNanos = 0
+	result, err = convertProfile(prof, TypeGoroutine)
+	if err != nil {
+		t.Fatalf("convertProfile failed: %v", err)
+	}
+	if !result.Time.IsZero() {
+		t.Errorf("got time %v, want zero time", result.Time)
+	}
+}
+
+// TestConvertProfileSampleTypes tests that sample values are read by sample
+// type name rather than position
+func TestConvertProfileSampleTypes(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main.work"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	newProfile := func(defaultType string, values []int64, types ...*profile.ValueType) *profile.Profile {
+		return &profile.Profile{
+			SampleType:        types,
+			DefaultSampleType: defaultType,
+			PeriodType:        &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+			Period:            10,
+			Sample:            []*profile.Sample{{Location: []*profile.Location{loc}, Value: values}},
+			Location:          []*profile.Location{loc},
+			Function:          []*profile.Function{fn},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		prof        *profile.Profile
+		profileType ProfileType
+		sampleIndex string
+		wantTotal   int64
+		wantMetric  SampleType
+	}{
+		{
+			name:        "cpu time listed first, in milliseconds",
+			prof:        newProfile("", []int64{3, 7}, &profile.ValueType{Type: "cpu", Unit: "milliseconds"}, &profile.ValueType{Type: "samples", Unit: "count"}),
+			profileType: TypeCPU,
+			wantTotal:   3_000_000,
+			wantMetric:  SampleType{Type: "cpu", Unit: "nanoseconds"},
+		},
+		{
+			name:        "cpu samples scaled by period",
+			prof:        newProfile("", []int64{7}, &profile.ValueType{Type: "samples", Unit: "count"}),
+			profileType: TypeCPU,
+			wantTotal:   70,
+			wantMetric:  SampleType{Type: "samples", Unit: "nanoseconds"},
+		},
+		{
+			name:        "cpu sample index",
+			prof:        newProfile("", []int64{3, 7}, &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}, &profile.ValueType{Type: "samples", Unit: "count"}),
+			profileType: TypeCPU,
+			sampleIndex: "samples",
+			wantTotal:   7,
+			wantMetric:  SampleType{Type: "samples", Unit: "count"},
+		},
+		{
+			name:        "heap without alloc values",
+			prof:        newProfile("", []int64{2, 512}, &profile.ValueType{Type: "inuse_objects", Unit: "count"}, &profile.ValueType{Type: "inuse_space", Unit: "bytes"}),
+			profileType: TypeHeap,
+			wantTotal:   512,
+			wantMetric:  SampleType{Type: "inuse_space", Unit: "bytes"},
+		},
+		{
+			name:        "generic default sample type",
+			prof:        newProfile("wall", []int64{5, 9}, &profile.ValueType{Type: "wall", Unit: "seconds"}, &profile.ValueType{Type: "requests", Unit: "count"}),
+			profileType: TypeGeneric,
+			wantTotal:   5_000_000_000,
+			wantMetric:  SampleType{Type: "wall", Unit: "nanoseconds"},
+		},
+		{
+			name:        "generic last sample type",
+			prof:        newProfile("", []int64{5, 9}, &profile.ValueType{Type: "wall", Unit: "seconds"}, &profile.ValueType{Type: "requests", Unit: "count"}),
+			profileType: TypeGeneric,
+			wantTotal:   9,
+			wantMetric:  SampleType{Type: "requests", Unit: "count"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertProfileMetric(tt.prof, tt.profileType, tt.sampleIndex)
+			if err != nil {
+				t.Fatalf("convertProfileMetric failed: %v", err)
+			}
+			if result.TotalSamples != tt.wantTotal {
+				t.Errorf("TotalSamples = %d, want %d", result.TotalSamples, tt.wantTotal)
+			}
+			if result.Metric != tt.wantMetric {
+				t.Errorf("Metric = %+v, want %+v", result.Metric, tt.wantMetric)
+			}
+			if len(result.Functions) != 1 || result.Functions[0].Flat != tt.wantTotal || result.Functions[0].FlatPct != 100 {
+				t.Errorf("unexpected functions: %+v", result.Functions)
+			}
+		})
+	}
+
+	if _, err := convertProfileMetric(tests[0].prof, TypeCPU, "bogus"); err == nil || !strings.Contains(err.Error(), "available: cpu, samples") {
+		t.Errorf("expected an unknown sample type error listing the sample types, got %v", err)
+	}
+}
+
+// TestSelectSampleType tests switching the primary metric of a parsed profile
+func TestSelectSampleType(t *testing.T) {
+	testdataDir, err := filepath.Abs("../../testdata")
+	if err != nil {
+		t.Fatalf("failed to get testdata path: %v", err)
+	}
+	prof, err := NewParser(TypeHeap).Parse(filepath.Join(testdataDir, "heap.prof"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	inuse, err := prof.SelectSampleType("inuse_space")
+	if err != nil {
+		t.Fatalf("SelectSampleType failed: %v", err)
+	}
+	if inuse.Metric.Type != "inuse_space" || inuse.TotalSamples != prof.Stats.InUseBytes {
+		t.Errorf("got metric %s with total %d, want inuse_space with total %d", inuse.Metric.Type, inuse.TotalSamples, prof.Stats.InUseBytes)
+	}
+	if inuse.Stats.AllocBytes != prof.Stats.AllocBytes || inuse.Stats.InUseObjects != prof.Stats.InUseObjects {
+		t.Errorf("heap stats changed: got %+v, want %+v", inuse.Stats, prof.Stats)
+	}
+
+	if same, err := prof.SelectSampleType(""); err != nil || same != prof {
+		t.Errorf("SelectSampleType(\"\") = %p, %v; want the profile itself", same, err)
+	}
+}
+
+// TestParseGenericProfile tests that custom profiles are detected as generic
+func TestParseGenericProfile(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main.accept"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "example.com/conns", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "example.com/conns", Unit: "count"},
+		Period:     1,
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{4}}},
+		Location:   []*profile.Location{loc},
+		Function:   []*profile.Function{fn},
+	}
+	file := filepath.Join(t.TempDir(), "conns.prof")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("failed to create profile: %v", err)
+	}
+	if err := prof.Write(f); err != nil {
+		t.Fatalf("failed to write profile: %v", err)
+	}
+	f.Close()
+
+	result, err := Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result.Type != TypeGeneric {
+		t.Errorf("Type = %s, want %s", result.Type, TypeGeneric)
+	}
+	if result.TotalSamples != 4 || result.Metric.Type != "example.com/conns" {
+		t.Errorf("got total %d of %s, want 4 of example.com/conns", result.TotalSamples, result.Metric.Type)
+	}
+}
+
+// TestScoreProfileTypes tests scored profile type detection
+func TestScoreProfileTypes(t *testing.T) {
+	vt := func(typ, unit string) *profile.ValueType { return &profile.ValueType{Type: typ, Unit: unit} }
+
+	tests := []struct {
+		name           string
+		prof           *profile.Profile
+		wantType       ProfileType
+		wantConfidence float64
+	}{
+		{
+			name:           "go cpu profile",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("samples", "count"), vt("cpu", "nanoseconds")}, PeriodType: vt("cpu", "nanoseconds")},
+			wantType:       TypeCPU,
+			wantConfidence: 1,
+		},
+		{
+			name:           "in-use only heap profile",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("inuse_space", "bytes")}, PeriodType: vt("space", "bytes")},
+			wantType:       TypeHeap,
+			wantConfidence: 1,
+		},
+		{
+			name:           "block profile",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("contentions", "count"), vt("delay", "nanoseconds")}, PeriodType: vt("contentions", "count")},
+			wantType:       TypeMutex,
+			wantConfidence: 1,
+		},
+		{
+			name:           "custom profile",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("example.com/conns", "count")}, PeriodType: vt("example.com/conns", "count")},
+			wantType:       TypeGeneric,
+			wantConfidence: 1,
+		},
+		{
+			name:           "bytes alone are weak evidence",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("rss", "bytes")}},
+			wantType:       TypeGeneric,
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "a comment does not make bytes heap",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("rss", "bytes")}, Comments: []string{"Memory snapshot"}},
+			wantType:       TypeGeneric,
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "a comment corroborates a heap sample type",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("inuse_space", "bytes")}, Comments: []string{"Memory snapshot"}},
+			wantType:       TypeHeap,
+			wantConfidence: 0.75,
+		},
+		{
+			name:           "single weak candidate",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("inuse_space", "bytes")}},
+			wantType:       TypeHeap,
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "samples alone are not cpu",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("samples", "count")}, PeriodType: vt("samples", "count")},
+			wantType:       TypeGeneric,
+			wantConfidence: 1,
+		},
+		{
+			name:           "conflicting evidence",
+			prof:           &profile.Profile{SampleType: []*profile.ValueType{vt("samples", "count"), vt("cpu", "nanoseconds"), vt("alloc_space", "bytes"), vt("inuse_space", "bytes")}, PeriodType: vt("space", "bytes")},
+			wantType:       TypeHeap,
+			wantConfidence: 0.6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := scoreProfileTypes(tt.prof)
+			if err != nil {
+				t.Fatalf("scoreProfileTypes failed: %v", err)
+			}
+			if d.Type != tt.wantType {
+				t.Errorf("Type = %s, want %s\n%s", d.Type, tt.wantType, d.Explain())
+			}
+			if d.Confidence != tt.wantConfidence {
+				t.Errorf("Confidence = %.2f, want %.2f\n%s", d.Confidence, tt.wantConfidence, d.Explain())
+			}
+		})
+	}
+
+	if _, err := scoreProfileTypes(&profile.Profile{}); err == nil {
+		t.Error("expected an error for a profile without sample types")
+	}
+}
+
+// TestDetectionExplain tests the detection explanation
+func TestDetectionExplain(t *testing.T) {
+	d, err := scoreProfileTypes(&profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+	})
+	if err != nil {
+		t.Fatalf("scoreProfileTypes failed: %v", err)
+	}
+
+	explanation := d.Explain()
+	for _, want := range []string{
+		"Detected type: cpu (confidence 100%)",
+		"Format: pprof",
+		"- cpu (score 6)",
+		"  - sample type `cpu` (nanoseconds) measures CPU time",
+		"  - period type `cpu` (nanoseconds)",
+	} {
+		if !strings.Contains(explanation, want) {
+			t.Errorf("explanation missing %q:\n%s", want, explanation)
+		}
+	}
+}
+
+// TestParseFoldedStacks tests importing collapsed stacks
+func TestParseFoldedStacks(t *testing.T) {
+	folded := `# collapsed by stackcollapse-perf.pl
+main;work;hash 3
+main;work 1
+main;work;hash 2
+`
+	for name, eol := range map[string]string{"lf": "\n", "crlf": "\r\n"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "out.folded")
+			if err := os.WriteFile(file, []byte(strings.ReplaceAll(folded, "\n", eol)), 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			pType, err := DetectProfileType(file)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pType != TypeCPU {
+				t.Errorf("got type %s, want %s", pType, TypeCPU)
+			}
+
+			prof, err := Parse(file)
+			if err != nil {
+				t.Fatalf("failed to parse folded stacks: %v", err)
+			}
+
+			period := importedSamplePeriod.Nanoseconds()
+			if prof.TotalSamples != 6*period {
+				t.Errorf("total = %d, want %d", prof.TotalSamples, 6*period)
+			}
+			if len(prof.Samples) != 2 {
+				t.Fatalf("got %d samples, want 2", len(prof.Samples))
+			}
+			if got := prof.Samples[0]; got.Value != 5*period || len(got.Stack) != 3 || got.Stack[0] != "main" || got.Stack[2] != "hash" {
+				t.Errorf("first sample = %+v, want main;work;hash with 5 samples", got)
+			}
+			if prof.Functions[0].Name != "hash" || prof.Functions[0].Flat != 5*period {
+				t.Errorf("top function = %s (%d), want hash (%d)", prof.Functions[0].Name, prof.Functions[0].Flat, 5*period)
+			}
+		})
+	}
+}
+
+// TestParsePerfScript tests importing `perf script` output
+func TestParsePerfScript(t *testing.T) {
+	perf := `app  1234 [002]  5023.120482:     250000 cpu-clock:pppH:
+	    55d0c1a2b3c4 main.hash+0x24 (/usr/bin/app)
+	    55d0c1a2b000 main.work+0x10 (/usr/bin/app)
+	    7f0000001000 [unknown] (/usr/lib/libc.so.6)
+
+app  1234 [002]  5023.120732:     250000 cpu-clock:pppH:
+	    55d0c1a2b000 main.work+0x10 (/usr/bin/app)
+	    7f0000001000 [unknown] (/usr/lib/libc.so.6)
+`
+	file := filepath.Join(t.TempDir(), "perf.txt")
+	if err := os.WriteFile(file, []byte(perf), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	pType, err := DetectProfileType(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pType != TypeCPU {
+		t.Errorf("got type %s, want %s", pType, TypeCPU)
+	}
+
+	prof, err := Parse(file)
+	if err != nil {
+		t.Fatalf("failed to parse perf script: %v", err)
+	}
+
+	// cpu-clock periods are nanoseconds
+	if prof.TotalSamples != 500000 {
+		t.Errorf("total = %d, want 500000", prof.TotalSamples)
+	}
+	if prof.Stats.SampleRate != 4000 {
+		t.Errorf("sample rate = %d, want 4000", prof.Stats.SampleRate)
+	}
+	wantStack := []string{"[libc.so.6]", "main.work", "main.hash"}
+	stack := prof.Samples[0].Stack
+	if len(stack) != len(wantStack) {
+		t.Fatalf("stack = %v, want %v", stack, wantStack)
+	}
+	for i := range wantStack {
+		if stack[i] != wantStack[i] {
+			t.Errorf("stack = %v, want %v", stack, wantStack)
+		}
+	}
+}
+
+// TestParsePerfScriptPeriods tests that perf samples count once each, at
+// the average clock period or the default period for other events
+func TestParsePerfScriptPeriods(t *testing.T) {
+	sample := func(period int, event string) string {
+		return fmt.Sprintf("app  1234 [002]  5023.120482: %10d %s:\n\t    55d0c1a2b3c4 main.hash+0x24 (/usr/bin/app)\n\n", period, event)
+	}
+	tests := []struct {
+		name   string
+		perf   string
+		period int64
+	}{
+		{"unequal clock periods", sample(100000, "cpu-clock") + sample(300000, "task-clock"), 200000},
+		{"mixed events", sample(100000, "cpu-clock") + sample(300000, "cycles"), importedSamplePeriod.Nanoseconds()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "perf.txt")
+			if err := os.WriteFile(file, []byte(tt.perf), 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			prof, err := Parse(file)
+			if err != nil {
+				t.Fatalf("failed to parse perf script: %v", err)
+			}
+
+			if prof.TotalSamples != 2*tt.period {
+				t.Errorf("total = %d, want %d", prof.TotalSamples, 2*tt.period)
+			}
+			if prof.Functions[0].Name != "main.hash" || prof.Functions[0].Flat != 2*tt.period {
+				t.Errorf("top function = %s (%d), want main.hash (%d)", prof.Functions[0].Name, prof.Functions[0].Flat, 2*tt.period)
+			}
+		})
+	}
+}
+
+// TestDetectTextFormats tests text format detection
+func TestDetectTextFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		folded bool
+		perf   bool
+	}{
+		{"folded", "a;b 1\na 2\n", true, false},
+		{"folded with spaces in frames", "main.F[go.shape.struct { X int }];b 10\n", true, false},
+		{"perf script", "app 1 [000] 1.0: 1 cycles:\n\t  ffff a+0x1 (/bin/app)\n", false, true},
+		{"goroutine dump", "goroutine 1 [running]:\nmain.main()\n", false, false},
+		{"plain text", "hello world\n", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFoldedStacks([]byte(tt.data)); got != tt.folded {
+				t.Errorf("isFoldedStacks = %v, want %v", got, tt.folded)
+			}
+			if got := isPerfScript([]byte(tt.data)); got != tt.perf {
+				t.Errorf("isPerfScript = %v, want %v", got, tt.perf)
+			}
+		})
+	}
+}
+
+// TestParseExecutionTrace tests profiles derived from a runtime/trace file
+func TestParseExecutionTrace(t *testing.T) {
+	traceFile := filepath.Join(t.TempDir(), "trace.out")
+	f, err := os.Create(traceFile)
+	if err != nil {
+		t.Fatalf("failed to create trace file: %v", err)
+	}
+	if err := trace.Start(f); err != nil {
+		t.Fatalf("failed to start trace: %v", err)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	mu.Lock()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+			mu.Lock()
+			mu.Unlock()
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	close(release)
+	time.Sleep(5 * time.Millisecond)
+	mu.Unlock()
+	wg.Wait()
+	trace.Stop()
+	f.Close()
+
+	profileType, err := DetectProfileType(traceFile)
+	if err != nil {
+		t.Fatalf("DetectProfileType failed: %v", err)
+	}
+	if profileType != TypeSched {
+		t.Errorf("DetectProfileType = %s, want %s", profileType, TypeSched)
+	}
+
+	for _, typ := range []ProfileType{TypeSched, TypeSyscall, TypeNet, TypeSync} {
+		prof, err := NewParser(typ).Parse(traceFile)
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", typ, err)
+		}
+		if prof.Type != typ {
+			t.Errorf("Type = %s, want %s", prof.Type, typ)
+		}
+		if prof.Trace == nil || prof.Trace.Duration <= 0 || prof.Trace.Goroutines < 5 {
+			t.Errorf("%s: unexpected trace summary %+v", typ, prof.Trace)
+		}
+		if prof.TotalSamples != prof.Stats.TotalContentionTime {
+			t.Errorf("%s: TotalSamples = %d, want %d", typ, prof.TotalSamples, prof.Stats.TotalContentionTime)
+		}
+	}
+
+	prof, err := NewParser(TypeSync).Parse(traceFile)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	waits := map[string]int64{}
+	for _, fn := range prof.Functions {
+		waits[fn.Name] = fn.Flat
+	}
+	for _, name := range []string{"sync.(*Mutex).Lock", "runtime.chanrecv1"} {
+		if waits[name] < int64(time.Millisecond) {
+			t.Errorf("sync wait of %s = %d, want at least 1ms", name, waits[name])
+		}
+	}
+	if prof.Stats.TotalWaits < 8 {
+		t.Errorf("TotalWaits = %d, want at least 8", prof.Stats.TotalWaits)
+	}
+}
+
+// TestConvertProfileEdges tests caller and callee edge weights
+func TestConvertProfileEdges(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.leaf()
+	/src/app/main.go:5 +0x1d
+main.walk()
+	/src/app/main.go:10 +0x1d
+main.walk()
+	/src/app/main.go:10 +0x1d
+main.main()
+	/src/app/main.go:20 +0x1d
+
+goroutine 2 [running]:
+main.leaf()
+	/src/app/main.go:5 +0x1d
+main.main()
+	/src/app/main.go:21 +0x1d
+`
+	result, err := parseGoroutineDump([]byte(dump))
+	if err != nil {
+		t.Fatalf("parseGoroutineDump failed: %v", err)
+	}
+
+	functions := make(map[string]Function)
+	for _, fn := range result.Functions {
+		functions[fn.Name] = fn
+	}
+
+	tests := []struct {
+		name     string
+		edges    []Edge
+		expected []Edge
+	}{
+		{"main.main callees", functions["main.main"].Callees, []Edge{{"main.leaf", 1}, {"main.walk", 1}}},
+		{"main.main callers", functions["main.main"].Callers, nil},
+		{"main.walk callers", functions["main.walk"].Callers, []Edge{{"main.main", 1}, {"main.walk", 1}}},
+		{"main.walk callees", functions["main.walk"].Callees, []Edge{{"main.leaf", 1}, {"main.walk", 1}}},
+		{"main.leaf callers", functions["main.leaf"].Callers, []Edge{{"main.main", 1}, {"main.walk", 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.edges) != len(tt.expected) {
+				t.Fatalf("got %v, want %v", tt.edges, tt.expected)
+			}
+			for i := range tt.edges {
+				if tt.edges[i] != tt.expected[i] {
+					t.Errorf("edge %d = %v, want %v", i, tt.edges[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+// TestParseGoroutineDump tests parsing debug=2 goroutine dumps
+func TestParseGoroutineDump(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:30 +0x1d
+
+goroutine 18 [chan receive, 5 minutes]:
+runtime.gopark(0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:398 +0xce
+main.(*Pool).worker(0xc0000a0000)
+	/src/app/pool.go:42 +0x45
+created by main.(*Pool).Start in goroutine 1
+	/src/app/pool.go:20 +0x35
+
+goroutine 19 [chan receive, 5 minutes]:
+runtime.gopark(0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:398 +0xce
+main.(*Pool).worker(0xc0000a0000)
+	/src/app/pool.go:42 +0x45
+created by main.(*Pool).Start in goroutine 1
+	/src/app/pool.go:20 +0x35
+`
+	dumpFile := filepath.Join(t.TempDir(), "goroutine.txt")
+	if err := os.WriteFile(dumpFile, []byte(dump), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	pType, err := DetectProfileType(dumpFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pType != TypeGoroutine {
+		t.Errorf("got type %s, want %s", pType, TypeGoroutine)
+	}
+
+	prof, err := Parse(dumpFile)
+	if err != nil {
+		t.Fatalf("failed to parse goroutine dump: %v", err)
+	}
+	if prof.Stats.TotalGoroutines != 3 {
+		t.Errorf("got %d goroutines, want 3", prof.Stats.TotalGoroutines)
+	}
+
+	// Identical goroutines are merged into one sample
+	if len(prof.Samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(prof.Samples))
+	}
+	worker := prof.Samples[1]
+	if worker.Value != 2 {
+		t.Errorf("worker sample value = %d, want 2", worker.Value)
+	}
+	if worker.CreatedBy != "main.(*Pool).Start" {
+		t.Errorf("worker created by = %q, want main.(*Pool).Start", worker.CreatedBy)
+	}
+	wantStack := []string{"main.(*Pool).worker", "runtime.gopark"}
+	if len(worker.Stack) != len(wantStack) {
+		t.Fatalf("worker stack = %v, want %v", worker.Stack, wantStack)
+	}
+	for i := range wantStack {
+		if worker.Stack[i] != wantStack[i] {
+			t.Errorf("worker stack = %v, want %v", worker.Stack, wantStack)
+		}
+	}
+
+	for _, fn := range prof.Functions {
+		if fn.Name == "main.(*Pool).worker" && (fn.File != "/src/app/pool.go" || fn.Line != 42) {
+			t.Errorf("worker location = %s:%d, want /src/app/pool.go:42", fn.File, fn.Line)
+		}
+	}
+
+	// Panic tracebacks start with the panic message
+	traceback := "panic: runtime error: invalid memory address or nil pointer dereference\n" +
+		"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x1000]\n\n" + dump
+	if !isGoroutineDump([]byte(traceback)) {
+		t.Error("panic traceback not detected as a goroutine dump")
+	}
+	if !isGoroutineDump([]byte("fatal error: all goroutines are asleep - deadlock!\n\n" + dump)) {
+		t.Error("fatal error traceback not detected as a goroutine dump")
+	}
+	if isGoroutineDump([]byte("some log line\n\n" + dump)) {
+		t.Error("text before the first goroutine detected as a goroutine dump")
+	}
+}
+
+// TestFilterProfile tests focusing and ignoring samples by function name
+func TestFilterProfile(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:30 +0x1d
+
+goroutine 18 [chan receive]:
+runtime.gopark(0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:398 +0xce
+main.(*Pool).worker(0xc0000a0000)
+	/src/app/pool.go:42 +0x45
+created by main.(*Pool).Start in goroutine 1
+	/src/app/pool.go:20 +0x35
+`
+	prof, err := ParseData([]byte(dump), "")
+	if err != nil {
+		t.Fatalf("ParseData failed: %v", err)
+	}
+	if prof.Type != TypeGoroutine || prof.TotalSamples != 2 {
+		t.Fatalf("got %s profile with %d goroutines, want goroutine with 2", prof.Type, prof.TotalSamples)
+	}
+
+	if same, err := prof.Filter(nil, nil); err != nil || same != prof {
+		t.Errorf("Filter(nil, nil) = %p, %v; want the profile itself", same, err)
+	}
+
+	tests := []struct {
+		name      string
+		focus     string
+		ignore    string
+		wantTotal int64
+		wantFuncs int
+	}{
+		{name: "focus", focus: `Pool`, wantTotal: 1, wantFuncs: 2},
+		{name: "ignore", ignore: `gopark`, wantTotal: 1, wantFuncs: 1},
+		{name: "focus and ignore", focus: `main\.`, ignore: `worker`, wantTotal: 1, wantFuncs: 1},
+		{name: "no match", focus: `nothing`, wantTotal: 0, wantFuncs: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var focus, ignore *regexp.Regexp
+			if tt.focus != "" {
+				focus = regexp.MustCompile(tt.focus)
+			}
+			if tt.ignore != "" {
+				ignore = regexp.MustCompile(tt.ignore)
+			}
+			result, err := prof.Filter(focus, ignore)
+			if err != nil {
+				t.Fatalf("Filter failed: %v", err)
+			}
+			if result.TotalSamples != tt.wantTotal || len(result.Functions) != tt.wantFuncs {
+				t.Errorf("got total %d and %d functions, want %d and %d", result.TotalSamples, len(result.Functions), tt.wantTotal, tt.wantFuncs)
+			}
+			// Percentages are of the filtered total
+			if tt.wantFuncs > 0 && result.Functions[0].FlatPct != 100 {
+				t.Errorf("top function flat %% = %.2f, want 100", result.Functions[0].FlatPct)
+			}
+		})
+	}
+
+	worker, err := prof.Filter(regexp.MustCompile(`worker`), nil)
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if len(worker.Samples) != 1 || worker.Samples[0].CreatedBy != "main.(*Pool).Start" {
+		t.Errorf("filtered samples = %+v, want the worker created by main.(*Pool).Start", worker.Samples)
+	}
+	if len(prof.Samples) != 2 {
+		t.Errorf("filtering changed the original profile: %d samples", len(prof.Samples))
+	}
+}
+
+// TestConvertProfileLines tests per-line values, heaviest line first
+func TestConvertProfileLines(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main.work", Filename: "main.go"}
+	caller := &profile.Function{ID: 2, Name: "main.main", Filename: "main.go"}
+	first := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn, Line: 10}}}
+	hot := &profile.Location{ID: 2, Line: []profile.Line{{Function: fn, Line: 12}}}
+	call := &profile.Location{ID: 3, Line: []profile.Line{{Function: caller, Line: 30}}}
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{first, call}, Value: []int64{100}},
+			{Location: []*profile.Location{hot, call}, Value: []int64{300}},
+		},
+		Location: []*profile.Location{first, hot, call},
+		Function: []*profile.Function{fn, caller},
+	}
+
+	result, err := convertProfile(prof, TypeCPU)
+	if err != nil {
+		t.Fatalf("convertProfile failed: %v", err)
+	}
+	work := result.Functions[0]
+	want := []LineValue{{Line: 12, Flat: 300, Cum: 300}, {Line: 10, Flat: 100, Cum: 100}}
+	if work.Name != "main.work" || len(work.Lines) != len(want) || work.Lines[0] != want[0] || work.Lines[1] != want[1] {
+		t.Errorf("%s lines = %+v, want %+v", work.Name, work.Lines, want)
+	}
+	if work.Line != 10 {
+		t.Errorf("%s line = %d, want the first sampled line 10", work.Name, work.Line)
+	}
+}
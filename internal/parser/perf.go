@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// perfFrameRE matches a stack frame of `perf script` output, e.g.
+// "	    55d0c1a2b3c4 main.work+0x24 (/usr/bin/app)"
+var perfFrameRE = regexp.MustCompile(`^\s+([0-9a-fA-F]+)\s+(.+?)(?:\s+\(([^()]*)\))?$`)
+
+// perfHeaderRE extracts the period and event from a `perf script` sample
+// header, e.g. "app 1234 [002] 5023.120482:   250000 cpu-clock:pppH:"
+var perfHeaderRE = regexp.MustCompile(`\s\d+\.\d+:\s+(?:(\d+)\s+)?([\w.:-]+?):?\s*$`)
+
+// perfSymbolOffsetRE matches the "+0x24" offset suffix of a symbol
+var perfSymbolOffsetRE = regexp.MustCompile(`\+0x[0-9a-fA-F]+$`)
+
+// perfSample is one sample of `perf script` output
+type perfSample struct {
+	period int64
+	event  string
+	frames []dumpFrame // Leaf first
+}
+
+// isPerfScript reports whether data looks like `perf script` output: a
+// sample header followed by indented frames with addresses
+func isPerfScript(data []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	header := false
+	for lines := 0; s.Scan() && lines < maxDetectLines; lines++ {
+		line := s.Text()
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			header = false
+		case header:
+			return perfFrameRE.MatchString(line)
+		case line[0] == ' ' || line[0] == '\t':
+			return false
+		default:
+			header = true
+		}
+	}
+	return false
+}
+
+// parsePerfScript parses `perf script` output into a CPU profile. Each
+// sample counts once; when every sample is a cpu-clock or task-clock event
+// with a recorded period, the average period is used as the sampling period,
+// otherwise the default imported period is.
+func parsePerfScript(data []byte) (*Profile, error) {
+	samples, err := scanPerfScript(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples found in perf script output")
+	}
+
+	// Clock events record their period in nanoseconds; use the average as
+	// the sampling period so durations and the sample rate come out right
+	period := importedSamplePeriod.Nanoseconds()
+	var clockPeriod, clockSamples int64
+	for _, s := range samples {
+		if (s.event == "cpu-clock" || s.event == "task-clock") && s.period > 0 {
+			clockPeriod += s.period
+			clockSamples++
+		}
+	}
+	if clockSamples == int64(len(samples)) {
+		period = max(clockPeriod/clockSamples, 1)
+	}
+
+	b := newTextProfileBuilder("samples", "count", period, "cpu", "nanoseconds")
+	for _, s := range samples {
+		if len(s.frames) > 0 {
+			b.add("", s.frames, 1)
+		}
+	}
+	if len(b.prof.Sample) == 0 {
+		return nil, fmt.Errorf("no stacks found in perf script output")
+	}
+
+	return convertProfile(b.prof, TypeCPU)
+}
+
+// scanPerfScript splits `perf script` output into samples
+func scanPerfScript(data []byte) ([]perfSample, error) {
+	var samples []perfSample
+	var current *perfSample
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			current = nil
+		case strings.HasPrefix(line, "#"):
+			// Header comments written by `perf script --header`
+		case line[0] != ' ' && line[0] != '\t':
+			samples = append(samples, perfSample{})
+			current = &samples[len(samples)-1]
+			if m := perfHeaderRE.FindStringSubmatch(line); m != nil {
+				current.period, _ = strconv.ParseInt(m[1], 10, 64)
+				current.event = strings.SplitN(m[2], ":", 2)[0]
+			}
+		case current != nil:
+			m := perfFrameRE.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			current.frames = append(current.frames, dumpFrame{Function: perfSymbol(m[2], m[3])})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read perf script output: %w", err)
+	}
+	return samples, nil
+}
+
+// perfSymbol returns the function name of a frame, dropping the offset and
+// naming unknown symbols after their module, e.g. "[libc.so.6]"
+func perfSymbol(symbol, module string) string {
+	symbol = perfSymbolOffsetRE.ReplaceAllString(symbol, "")
+	if symbol == "[unknown]" && module != "" && module != "[unknown]" {
+		return "[" + filepath.Base(module) + "]"
+	}
+	return symbol
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// textProfileBuilder builds a pprof profile from stacks read out of a text
+// format, such as goroutine dumps, folded stacks or perf script output.
+// Samples with the same key and frames are merged.
+type textProfileBuilder struct {
+	prof      *profile.Profile
+	functions map[string]*profile.Function
+	locations map[dumpFrame]*profile.Location
+	samples   map[string]*profile.Sample
+}
+
+// newTextProfileBuilder returns a builder for a profile with a single sample
+// type whose values are multiplied by period (in periodUnit) when converted
+func newTextProfileBuilder(sampleType, unit string, period int64, periodType, periodUnit string) *textProfileBuilder {
+	return &textProfileBuilder{
+		prof: &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: sampleType, Unit: unit}},
+			PeriodType: &profile.ValueType{Type: periodType, Unit: periodUnit},
+			Period:     period,
+		},
+		functions: make(map[string]*profile.Function),
+		locations: make(map[dumpFrame]*profile.Location),
+		samples:   make(map[string]*profile.Sample),
+	}
+}
+
+// add records values, one per sample type, for a stack of frames, leaf
+// first. It reports whether the sample is new rather than merged into an
+// earlier one with the same key and frames.
+func (b *textProfileBuilder) add(key string, frames []dumpFrame, values ...int64) bool {
+	locs := make([]*profile.Location, 0, len(frames))
+	keyParts := []string{key}
+	for _, fr := range frames {
+		loc, ok := b.locations[fr]
+		if !ok {
+			fnKey := fr.Function + "\x00" + fr.File
+			fn, ok := b.functions[fnKey]
+			if !ok {
+				fn = &profile.Function{
+					ID:         uint64(len(b.prof.Function) + 1),
+					Name:       fr.Function,
+					SystemName: fr.Function,
+					Filename:   fr.File,
+				}
+				b.functions[fnKey] = fn
+				b.prof.Function = append(b.prof.Function, fn)
+			}
+			loc = &profile.Location{
+				ID:   uint64(len(b.prof.Location) + 1),
+				Line: []profile.Line{{Function: fn, Line: fr.Line}},
+			}
+			b.locations[fr] = loc
+			b.prof.Location = append(b.prof.Location, loc)
+		}
+		locs = append(locs, loc)
+		keyParts = append(keyParts, strconv.FormatUint(loc.ID, 10))
+	}
+
+	sampleKey := strings.Join(keyParts, "\x00")
+	if sample, ok := b.samples[sampleKey]; ok {
+		for i, v := range values {
+			sample.Value[i] += v
+		}
+		return false
+	}
+	sample := &profile.Sample{Location: locs, Value: append([]int64(nil), values...)}
+	b.samples[sampleKey] = sample
+	b.prof.Sample = append(b.prof.Sample, sample)
+	return true
+}
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"golang.org/x/exp/trace"
+)
+
+// traceHeaderRE matches the header of an execution trace, e.g. "go 1.23 trace"
+var traceHeaderRE = regexp.MustCompile(`^go 1\.\d+ trace\x00\x00\x00`)
+
+// gomaxprocsMetric is the trace metric recording GOMAXPROCS
+const gomaxprocsMetric = "/sched/gomaxprocs:threads"
+
+// TraceParser derives a profile from a Go execution trace, as written by
+// runtime/trace or /debug/pprof/trace
+type TraceParser struct {
+	Type ProfileType // TypeSched, TypeSyscall, TypeNet or TypeSync
+}
+
+// TraceSummary describes the execution trace a profile was derived from
+type TraceSummary struct {
+	Duration        time.Duration
+	GOMAXPROCS      int
+	Goroutines      int // Goroutines seen in the trace
+	GCCycles        int
+	GCPauses        int // Stop-the-world pauses
+	GCPauseTotal    time.Duration
+	GCPauseMax      time.Duration
+	ProcUtilization float64 // Percentage of GOMAXPROCS × Duration spent running goroutines
+}
+
+// traceWait is a goroutine entering the state a trace profile measures
+type traceWait struct {
+	start trace.Time
+	stack trace.Stack
+}
+
+// traceRange identifies an open range, such as a stop-the-world pause
+type traceRange struct {
+	name  string
+	scope trace.ResourceID
+}
+
+// Parse parses an execution trace file into the parser's profile type
+func (p *TraceParser) Parse(filename string) (*Profile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	return ParseData(data, p.Type)
+}
+
+// DetectType returns the profile type derived by the parser
+func (p *TraceParser) DetectType() (ProfileType, error) {
+	return p.Type, nil
+}
+
+// isExecutionTrace reports whether data starts with an execution trace header
+func isExecutionTrace(data []byte) bool {
+	return traceHeaderRE.Match(data)
+}
+
+// traceWaitState returns the goroutine state measured by a trace profile
+// type, and which transition reasons into that state count. They match the
+// profiles of `go tool trace -pprof`.
+func traceWaitState(profileType ProfileType) (trace.GoState, func(reason string) bool, error) {
+	anyReason := func(string) bool { return true }
+	switch profileType {
+	case TypeSched:
+		return trace.GoRunnable, anyReason, nil
+	case TypeSyscall:
+		return trace.GoSyscall, anyReason, nil
+	case TypeNet:
+		return trace.GoWaiting, func(reason string) bool {
+			return reason == "network"
+		}, nil
+	case TypeSync:
+		return trace.GoWaiting, func(reason string) bool {
+			return strings.Contains(reason, "chan") || strings.Contains(reason, "sync") || strings.Contains(reason, "select")
+		}, nil
+	default:
+		return trace.GoUndetermined, nil, fmt.Errorf("unsupported trace profile type: %s", profileType)
+	}
+}
+
+// parseExecutionTrace derives a profile of the time goroutines spent in the
+// state measured by profileType, keyed by the stack at which they entered
+// it, and summarizes the trace as a whole
+func parseExecutionTrace(r io.Reader, profileType ProfileType) (*Profile, error) {
+	state, countReason, err := traceWaitState(profileType)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := trace.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+
+	// Same layout as mutex profiles: [contentions, delay]
+	b := newTextProfileBuilder("contentions", "count", 1, "contentions", "count")
+	b.prof.SampleType = append(b.prof.SampleType, &profile.ValueType{Type: "delay", Unit: "nanoseconds"})
+
+	summary := &TraceSummary{}
+	waiting := make(map[trace.GoID]traceWait)
+	running := make(map[trace.GoID]trace.Time)
+	goroutines := make(map[trace.GoID]bool)
+	procs := make(map[trace.ProcID]bool)
+	ranges := make(map[traceRange]trace.Time)
+	var first, last trace.Time
+	var runTime time.Duration
+
+	for {
+		ev, err := rd.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trace: %w", err)
+		}
+
+		t := ev.Time()
+		if first == 0 {
+			first = t
+		}
+		last = t
+		if p := ev.Proc(); p != trace.NoProc {
+			procs[p] = true
+		}
+
+		switch ev.Kind() {
+		case trace.EventMetric:
+			if m := ev.Metric(); m.Name == gomaxprocsMetric {
+				summary.GOMAXPROCS = int(m.Value.Uint64())
+			}
+
+		case trace.EventRangeBegin:
+			rg := ev.Range()
+			ranges[traceRange{rg.Name, rg.Scope}] = t
+
+		case trace.EventRangeEnd:
+			rg := ev.Range()
+			key := traceRange{rg.Name, rg.Scope}
+			start, ok := ranges[key]
+			if !ok {
+				continue
+			}
+			delete(ranges, key)
+			switch {
+			case strings.HasPrefix(rg.Name, "stop-the-world"):
+				pause := t.Sub(start)
+				summary.GCPauses++
+				summary.GCPauseTotal += pause
+				summary.GCPauseMax = max(summary.GCPauseMax, pause)
+			case rg.Name == "GC concurrent mark phase":
+				summary.GCCycles++
+			}
+
+		case trace.EventStateTransition:
+			st := ev.StateTransition()
+			if st.Resource.Kind != trace.ResourceGoroutine {
+				continue
+			}
+			id := st.Resource.Goroutine()
+			from, to := st.Goroutine()
+			goroutines[id] = true
+
+			switch {
+			case to == trace.GoRunning && from != trace.GoRunning:
+				running[id] = t
+			case from == trace.GoRunning && to != trace.GoRunning:
+				if start, ok := running[id]; ok {
+					runTime += t.Sub(start)
+					delete(running, id)
+				}
+			}
+
+			if w, ok := waiting[id]; ok {
+				if to != state {
+					delete(waiting, id)
+					b.add("", traceFrames(w.stack), 1, int64(t.Sub(w.start)))
+				}
+				continue
+			}
+			if to == state && countReason(st.Reason) {
+				stack := ev.Stack()
+				if stack == trace.NoStack {
+					stack = st.Stack
+				}
+				if stack != trace.NoStack {
+					waiting[id] = traceWait{start: t, stack: stack}
+				}
+			}
+		}
+	}
+	if first == 0 {
+		return nil, fmt.Errorf("no events found in trace")
+	}
+
+	// Goroutines still running when tracing stopped ran until the end
+	for _, start := range running {
+		runTime += last.Sub(start)
+	}
+
+	summary.Duration = last.Sub(first)
+	summary.Goroutines = len(goroutines)
+	if summary.GOMAXPROCS == 0 {
+		summary.GOMAXPROCS = len(procs)
+	}
+	if capacity := summary.Duration * time.Duration(summary.GOMAXPROCS); capacity > 0 {
+		summary.ProcUtilization = float64(runTime) / float64(capacity) * 100
+	}
+
+	b.prof.DurationNanos = summary.Duration.Nanoseconds()
+	result, err := convertProfile(b.prof, profileType)
+	if err != nil {
+		return nil, err
+	}
+	result.Trace = summary
+	return result, nil
+}
+
+// traceFrames converts a trace stack into frames, leaf first
+func traceFrames(stack trace.Stack) []dumpFrame {
+	var frames []dumpFrame
+	for f := range stack.Frames() {
+		frames = append(frames, dumpFrame{Function: f.Func, File: f.File, Line: int64(f.Line)})
+	}
+	return frames
+}
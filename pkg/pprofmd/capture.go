@@ -0,0 +1,111 @@
+package pprofmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"strings"
+	"time"
+)
+
+// CaptureProfiles profiles the current process in memory. Names are "cpu"
+// or runtime/pprof profile names such as "heap", "allocs", "goroutine",
+// "mutex" and "block". The CPU profile covers duration, or until ctx is
+// done; the others are snapshots taken after it. Parse options apply to
+// every profile.
+func CaptureProfiles(ctx context.Context, duration time.Duration, names []string, opts ...ParseOption) ([]*Profile, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no profiles to capture")
+	}
+	for _, name := range names {
+		if name != "cpu" && pprof.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown profile: %s", name)
+		}
+	}
+
+	// Profile the CPU first, so snapshots include what happened meanwhile
+	data := make([][]byte, len(names))
+	for _, cpuFirst := range []bool{true, false} {
+		for i, name := range names {
+			if (name == "cpu") != cpuFirst {
+				continue
+			}
+			var err error
+			if data[i], err = captureProfile(ctx, name, duration); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	profiles := make([]*Profile, 0, len(names))
+	for i, name := range names {
+		p, err := ParseBytes(data[i], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s profile: %w", name, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// Capture profiles the current process and returns a markdown report of
+// each profile, in the order named, parsed with parseOpts and rendered with
+// renderOpts. See CaptureProfiles.
+func Capture(ctx context.Context, duration time.Duration, names []string, parseOpts []ParseOption, renderOpts ...RenderOption) (string, error) {
+	var buf strings.Builder
+	if err := CaptureTo(ctx, &buf, duration, names, parseOpts, renderOpts...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// CaptureTo profiles the current process and writes a markdown report of
+// each profile to w. See CaptureProfiles.
+func CaptureTo(ctx context.Context, w io.Writer, duration time.Duration, names []string, parseOpts []ParseOption, renderOpts ...RenderOption) error {
+	profiles, err := CaptureProfiles(ctx, duration, names, parseOpts...)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range profiles {
+		md, err := p.Markdown(renderOpts...)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			md = "\n---\n\n" + md
+		}
+		if _, err := io.WriteString(w, md); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	return nil
+}
+
+// captureProfile writes a profile of the current process to memory
+func captureProfile(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+	if name != "cpu" {
+		if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+			return nil, fmt.Errorf("failed to write %s profile: %w", name, err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	if duration <= 0 {
+		return nil, fmt.Errorf("cpu profile requires a positive duration")
+	}
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+	timer := time.NewTimer(duration)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+	}
+	pprof.StopCPUProfile()
+	return buf.Bytes(), nil
+}
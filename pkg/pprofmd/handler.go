@@ -0,0 +1,172 @@
+package pprofmd
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCPUSeconds is the CPU profile duration when ?seconds is not set,
+// as in net/http/pprof
+const defaultCPUSeconds = 30
+
+// Handler returns a handler serving reports of the current process's
+// profiles, mirroring net/http/pprof. Mount it on a path ending in a slash:
+//
+//	http.Handle("/debug/pprof-md/", pprofmd.Handler())
+//
+// The last path element names the profile: "cpu" (or "profile"), or a
+// runtime/pprof profile such as "heap", "allocs", "goroutine", "mutex" or
+// "block"; an empty one lists them. Query parameters:
+//
+//   - seconds: CPU profile duration (default 30)
+//   - top: number of top functions (default 20)
+//   - focus, ignore: regexps selecting samples by function name
+//   - sample_index: sample type to report, e.g. inuse_space
+//   - format: markdown (default) or json
+func Handler() http.Handler {
+	return http.HandlerFunc(serveProfile)
+}
+
+// serveProfile captures and renders the profile named by the request path
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if name == "" {
+		serveIndex(w)
+		return
+	}
+	if name == "profile" {
+		name = "cpu"
+	}
+	if name != "cpu" && pprof.Lookup(name) == nil {
+		httpError(w, http.StatusNotFound, fmt.Sprintf("unknown profile: %s", name))
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "markdown" && format != "json" {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid format: %s (valid: markdown, json)", format))
+		return
+	}
+	parseOpts, renderOpts, err := queryOptions(query.Get)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var duration time.Duration
+	if name == "cpu" {
+		seconds := defaultCPUSeconds
+		if s := query.Get("seconds"); s != "" {
+			if seconds, err = strconv.Atoi(s); err != nil || seconds <= 0 {
+				httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid seconds: %s", s))
+				return
+			}
+		}
+		duration = time.Duration(seconds) * time.Second
+		if srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok && srv.WriteTimeout > 0 && duration >= srv.WriteTimeout {
+			httpError(w, http.StatusBadRequest, "profile duration exceeds server's WriteTimeout")
+			return
+		}
+	}
+
+	data, err := captureProfile(r.Context(), name, duration)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// The profile is well-formed, so parse errors come from the query,
+	// such as an unknown sample_index
+	profile, err := ParseBytes(data, parseOpts...)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var report, contentType string
+	if format == "json" {
+		report, err = profile.JSON(renderOpts...)
+		contentType = "application/json"
+	} else {
+		report, err = profile.Markdown(renderOpts...)
+		contentType = "text/markdown; charset=utf-8"
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	fmt.Fprint(w, report)
+}
+
+// queryOptions converts the report query parameters into parse and render
+// options
+func queryOptions(get func(string) string) ([]ParseOption, []RenderOption, error) {
+	var parseOpts []ParseOption
+	var renderOpts []RenderOption
+	if s := get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, nil, fmt.Errorf("invalid top: %s", s)
+		}
+		renderOpts = append(renderOpts, WithTopN(n))
+	}
+	for _, param := range []struct {
+		name   string
+		option func(*regexp.Regexp) ParseOption
+	}{
+		{"focus", WithFocus},
+		{"ignore", WithIgnore},
+	} {
+		if s := get(param.name); s != "" {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %w", param.name, err)
+			}
+			parseOpts = append(parseOpts, param.option(re))
+		}
+	}
+	if s := get("sample_index"); s != "" {
+		parseOpts = append(parseOpts, WithSampleIndex(s))
+	}
+	return parseOpts, renderOpts, nil
+}
+
+// serveIndex lists the profiles the handler serves
+func serveIndex(w http.ResponseWriter) {
+	names := []string{"cpu"}
+	for _, p := range pprof.Profiles() {
+		names = append(names, p.Name())
+	}
+	sort.Strings(names[1:])
+
+	var b strings.Builder
+	b.WriteString("# Profiles\n\n")
+	for _, name := range names {
+		link := name
+		if name == "cpu" {
+			link += fmt.Sprintf("?seconds=%d", defaultCPUSeconds)
+		}
+		fmt.Fprintf(&b, "- [%s](%s)\n", name, link)
+	}
+	b.WriteString("\nQuery parameters: `seconds` (cpu), `top`, `focus`, `ignore`, `sample_index`, `format=json`.\n")
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// httpError writes a plain text error response
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, msg)
+}
@@ -0,0 +1,249 @@
+// Package pprofmd converts Go pprof profiles into markdown reports for AI
+// analysis and human review, and into JSON reports for programs.
+//
+// A report is generated in two steps: parse a profile, then render it.
+//
+//	p, err := pprofmd.ParseFile("cpu.prof", pprofmd.WithFocus(regexp.MustCompile(`^main\.`)))
+//	if err != nil {
+//		return err
+//	}
+//	md, err := p.Markdown(pprofmd.WithTopN(10))
+//
+// # Compatibility
+//
+// The package follows the module's semantic version: exported identifiers
+// are not removed or changed incompatibly within a major version, and new
+// options and profile types may be added in minor versions.
+//
+// Markdown reports are written for reading and their layout may change in
+// any release. Programs should consume JSON reports instead, whose schema is
+// versioned by JSONSchemaVersion: fields are only added within a schema
+// version, and removing or redefining a field increments it.
+package pprofmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/alingse/go-pprof-md/internal/generator"
+	"github.com/alingse/go-pprof-md/internal/parser"
+)
+
+// JSONSchemaVersion is the "version" field of JSON reports
+const JSONSchemaVersion = generator.JSONSchemaVersion
+
+// ProfileType is the kind of a profile
+type ProfileType string
+
+// Profile types. Sched, syscall, net and sync profiles are derived from
+// execution traces.
+const (
+	TypeCPU       ProfileType = "cpu"
+	TypeHeap      ProfileType = "heap"
+	TypeGoroutine ProfileType = "goroutine"
+	TypeMutex     ProfileType = "mutex"
+	TypeGeneric   ProfileType = "generic"
+	TypeSched     ProfileType = "sched"
+	TypeSyscall   ProfileType = "syscall"
+	TypeNet       ProfileType = "net"
+	TypeSync      ProfileType = "sync"
+)
+
+// Profile is a parsed profile, ready to render
+type Profile struct {
+	profile     *parser.Profile
+	sampleIndex string
+}
+
+// Type returns the profile type
+func (p *Profile) Type() ProfileType {
+	return ProfileType(p.profile.Type)
+}
+
+// ParseOption configures parsing a profile
+type ParseOption func(*parseConfig)
+
+// RenderOption configures rendering a report
+type RenderOption func(*renderConfig)
+
+// parseConfig holds the options of a parse call
+type parseConfig struct {
+	profileType ProfileType
+	sampleIndex string
+	focus       *regexp.Regexp
+	ignore      *regexp.Regexp
+}
+
+// renderConfig holds the options of a render call
+type renderConfig struct {
+	topN      int
+	aiPrompt  bool
+	maxTokens int
+	maxPaths  int
+}
+
+// newParseConfig applies parse options over the defaults
+func newParseConfig(opts []ParseOption) *parseConfig {
+	c := &parseConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// newRenderConfig applies render options over the defaults
+func newRenderConfig(opts []RenderOption) *renderConfig {
+	c := &renderConfig{topN: 20, aiPrompt: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithType sets the profile type instead of detecting it from the content
+func WithType(t ProfileType) ParseOption {
+	return func(c *parseConfig) {
+		c.profileType = t
+	}
+}
+
+// WithSampleIndex selects the sample type reported as the primary metric,
+// such as "inuse_space", like `go tool pprof -sample_index`. Empty uses the
+// profile type's usual metric.
+func WithSampleIndex(name string) ParseOption {
+	return func(c *parseConfig) {
+		c.sampleIndex = name
+	}
+}
+
+// WithFocus keeps only samples with a function matching re
+func WithFocus(re *regexp.Regexp) ParseOption {
+	return func(c *parseConfig) {
+		c.focus = re
+	}
+}
+
+// WithIgnore drops samples with a function matching re
+func WithIgnore(re *regexp.Regexp) ParseOption {
+	return func(c *parseConfig) {
+		c.ignore = re
+	}
+}
+
+// WithTopN sets the number of top functions to report (default 20)
+func WithTopN(n int) RenderOption {
+	return func(c *renderConfig) {
+		c.topN = n
+	}
+}
+
+// WithAIPrompt enables or disables the AI analysis prompt of markdown
+// reports (default enabled)
+func WithAIPrompt(enable bool) RenderOption {
+	return func(c *renderConfig) {
+		c.aiPrompt = enable
+	}
+}
+
+// WithMaxTokens trims markdown reports to about n LLM tokens. Zero disables
+// the limit.
+func WithMaxTokens(n int) RenderOption {
+	return func(c *renderConfig) {
+		c.maxTokens = n
+	}
+}
+
+// WithMaxPaths limits the call paths reported per function. Zero reports
+// all of them.
+func WithMaxPaths(n int) RenderOption {
+	return func(c *renderConfig) {
+		c.maxPaths = n
+	}
+}
+
+// Parse reads a profile: a pprof profile (gzipped or not), a goroutine text
+// dump, folded stacks, perf script output or an execution trace
+func Parse(r io.Reader, opts ...ParseOption) (*Profile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	return ParseBytes(data, opts...)
+}
+
+// ParseFile reads a profile from a file
+func ParseFile(filename string, opts ...ParseOption) (*Profile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	return ParseBytes(data, opts...)
+}
+
+// ParseBytes reads a profile from memory
+func ParseBytes(data []byte, opts ...ParseOption) (*Profile, error) {
+	c := newParseConfig(opts)
+
+	profile, err := parser.ParseData(data, parser.ProfileType(c.profileType))
+	if err != nil {
+		return nil, err
+	}
+	if c.sampleIndex != "" {
+		// Fail early on an unknown sample type
+		if _, err := profile.SelectSampleType(c.sampleIndex); err != nil {
+			return nil, err
+		}
+	}
+	profile, err = profile.Filter(c.focus, c.ignore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter profile: %w", err)
+	}
+
+	return &Profile{profile: profile, sampleIndex: c.sampleIndex}, nil
+}
+
+// Markdown renders the profile as a markdown report
+func (p *Profile) Markdown(opts ...RenderOption) (string, error) {
+	return p.generator(opts).Generate()
+}
+
+// JSON renders the profile as a JSON report. Its schema is versioned by
+// JSONSchemaVersion.
+func (p *Profile) JSON(opts ...RenderOption) (string, error) {
+	return p.generator(opts).GenerateJSON()
+}
+
+// generator returns a report generator for the render options
+func (p *Profile) generator(opts []RenderOption) *generator.Generator {
+	c := newRenderConfig(opts)
+	return generator.NewGenerator(
+		p.profile,
+		generator.WithTopN(c.topN),
+		generator.WithAIPrompt(c.aiPrompt),
+		generator.WithMaxTokens(c.maxTokens),
+		generator.WithMaxPaths(c.maxPaths),
+		generator.WithSampleIndex(p.sampleIndex),
+	)
+}
+
+// Diff renders a markdown report of the functions that changed from base to
+// target, two profiles of the same type. WithTopN sets how many are shown.
+func Diff(base, target *Profile, opts ...RenderOption) (string, error) {
+	if base.Type() != target.Type() {
+		return "", fmt.Errorf("profile types do not match: base is %s, new is %s", base.Type(), target.Type())
+	}
+
+	baseProfile, err := base.profile.SelectSampleType(base.sampleIndex)
+	if err != nil {
+		return "", err
+	}
+	newProfile, err := target.profile.SelectSampleType(target.sampleIndex)
+	if err != nil {
+		return "", err
+	}
+
+	c := newRenderConfig(opts)
+	return generator.NewDiffGenerator(baseProfile, newProfile, generator.WithDiffTopN(c.topN)).Generate()
+}
@@ -0,0 +1,229 @@
+package pprofmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const goroutineDump = `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:30 +0x1d
+
+goroutine 18 [chan receive]:
+runtime.gopark(0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:398 +0xce
+main.(*Pool).worker(0xc0000a0000)
+	/src/app/pool.go:42 +0x45
+created by main.(*Pool).Start in goroutine 1
+	/src/app/pool.go:20 +0x35
+`
+
+// TestParse tests parsing from a reader and rendering markdown
+func TestParse(t *testing.T) {
+	p, err := Parse(strings.NewReader(goroutineDump), WithFocus(regexp.MustCompile(`worker`)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if p.Type() != TypeGoroutine {
+		t.Errorf("Type = %s, want %s", p.Type(), TypeGoroutine)
+	}
+
+	md, err := p.Markdown(WithTopN(5), WithAIPrompt(false))
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	for _, expected := range []string{"# goroutine Profile Analysis", "- **Total Goroutines:** 1", "main.(*Pool).worker"} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("markdown missing expected string: %s", expected)
+		}
+	}
+	if strings.Contains(md, "`main.main`") || strings.Contains(md, "AI Analysis Request") {
+		t.Errorf("markdown includes filtered functions or the AI prompt:\n%s", md)
+	}
+}
+
+// TestParseFileJSON tests the sample index option and JSON reports
+func TestParseFileJSON(t *testing.T) {
+	p, err := ParseFile(filepath.Join("..", "..", "testdata", "heap.prof"), WithSampleIndex("inuse_space"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	if p.Type() != TypeHeap {
+		t.Errorf("Type = %s, want %s", p.Type(), TypeHeap)
+	}
+
+	out, err := p.JSON(WithTopN(1))
+	if err != nil {
+		t.Fatalf("JSON failed: %v", err)
+	}
+	var report struct {
+		Version   int    `json:"version"`
+		Metric    string `json:"metric"`
+		Functions []struct {
+			Name string `json:"name"`
+		} `json:"functions"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Version != JSONSchemaVersion || report.Metric != "inuse_space" || len(report.Functions) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err := ParseFile(filepath.Join("..", "..", "testdata", "heap.prof"), WithSampleIndex("bogus")); err == nil {
+		t.Error("expected an error for an unknown sample index")
+	}
+	if _, err := ParseBytes([]byte(goroutineDump), WithType("bogus")); err == nil {
+		t.Error("expected an error for an unknown profile type")
+	}
+}
+
+// TestDiff tests diff reports and type checks
+func TestDiff(t *testing.T) {
+	base, err := ParseFile(filepath.Join("..", "..", "testdata", "base.prof"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	newer, err := ParseFile(filepath.Join("..", "..", "testdata", "new.prof"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	md, err := Diff(base, newer, WithTopN(5))
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !strings.Contains(md, "# heap Profile Diff: Base vs New") {
+		t.Errorf("unexpected diff report:\n%s", md)
+	}
+
+	goroutines, err := ParseBytes([]byte(goroutineDump))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if _, err := Diff(base, goroutines); err == nil || !strings.Contains(err.Error(), "do not match") {
+		t.Errorf("expected a type mismatch error, got %v", err)
+	}
+}
+
+// TestCapture tests profiling the test process in memory
+func TestCapture(t *testing.T) {
+	md, err := Capture(context.Background(), 50*time.Millisecond, []string{"goroutine", "cpu", "heap"}, nil, WithTopN(3))
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	goroutine := strings.Index(md, "# goroutine Profile Analysis")
+	cpu := strings.Index(md, "# cpu Profile Analysis")
+	heap := strings.Index(md, "# heap Profile Analysis")
+	if goroutine < 0 || cpu < goroutine || heap < cpu {
+		t.Errorf("reports missing or out of order:\n%s", md)
+	}
+	if !strings.Contains(md, "TestCapture") {
+		t.Errorf("goroutine report does not show the test goroutine:\n%s", md)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	profiles, err := CaptureProfiles(ctx, time.Hour, []string{"cpu"})
+	if err != nil {
+		t.Fatalf("CaptureProfiles failed: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].Type() != TypeCPU || time.Since(start) > time.Minute {
+		t.Errorf("canceled capture returned %d profiles after %s", len(profiles), time.Since(start))
+	}
+
+	for _, names := range [][]string{nil, {"bogus"}} {
+		if _, err := Capture(context.Background(), 0, names, nil); err == nil {
+			t.Errorf("expected an error capturing %v", names)
+		}
+	}
+	if _, err := Capture(context.Background(), 0, []string{"cpu"}, nil); err == nil {
+		t.Error("expected an error capturing a cpu profile without a duration")
+	}
+}
+
+// TestHandler tests serving reports over HTTP
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/pprof-md/", Handler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantType    string
+		wantStrings []string
+	}{
+		{
+			name:        "index",
+			path:        "/debug/pprof-md/",
+			wantStatus:  http.StatusOK,
+			wantType:    "text/markdown; charset=utf-8",
+			wantStrings: []string{"- [cpu](cpu?seconds=30)", "- [goroutine](goroutine)", "- [heap](heap)"},
+		},
+		{
+			name:        "goroutine markdown",
+			path:        "/debug/pprof-md/goroutine?top=5&focus=TestHandler",
+			wantStatus:  http.StatusOK,
+			wantType:    "text/markdown; charset=utf-8",
+			wantStrings: []string{"# goroutine Profile Analysis", "TestHandler"},
+		},
+		{
+			name:        "heap json",
+			path:        "/debug/pprof-md/heap?format=json&sample_index=inuse_space",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json",
+			wantStrings: []string{`"type": "heap"`, `"metric": "inuse_space"`},
+		},
+		{
+			name:        "cpu",
+			path:        "/debug/pprof-md/profile?seconds=1&format=json",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json",
+			wantStrings: []string{`"type": "cpu"`},
+		},
+		{name: "unknown profile", path: "/debug/pprof-md/bogus", wantStatus: http.StatusNotFound},
+		{name: "invalid seconds", path: "/debug/pprof-md/cpu?seconds=x", wantStatus: http.StatusBadRequest},
+		{name: "invalid top", path: "/debug/pprof-md/heap?top=0", wantStatus: http.StatusBadRequest},
+		{name: "invalid focus", path: "/debug/pprof-md/heap?focus=(", wantStatus: http.StatusBadRequest},
+		{name: "invalid format", path: "/debug/pprof-md/heap?format=svg", wantStatus: http.StatusBadRequest},
+		{name: "unknown sample index", path: "/debug/pprof-md/heap?sample_index=bogus", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantType != "" && resp.Header.Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", resp.Header.Get("Content-Type"), tt.wantType)
+			}
+			for _, expected := range tt.wantStrings {
+				if !strings.Contains(string(body), expected) {
+					t.Errorf("body missing expected string %s:\n%s", expected, body)
+				}
+			}
+		})
+	}
+}